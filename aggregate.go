@@ -0,0 +1,36 @@
+package main
+
+import "github.com/kr328/domains2providers/trie"
+
+// matches 判断规则集 name 的 tag 是否属于该聚合
+func (a *aggregate) matches(name, tag string) bool {
+	for _, member := range a.Members {
+		if matchOutput(member, name, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// collectAggregates 将规则集 name 的 tag 中的规则并入所有包含它的聚合，added 记录每个聚合并入的规则条数
+func collectAggregates(aggregated map[string]*trie.Trie, added map[string]int, name, tag string, rules []string) {
+	for i := range aggregates {
+		a := &aggregates[i]
+		if !a.matches(name, tag) {
+			continue
+		}
+
+		domains, ok := aggregated[a.Name]
+		if !ok {
+			domains = trie.New()
+			aggregated[a.Name] = domains
+		}
+
+		for _, rule := range rules {
+			if domains.Add(rule) == nil {
+				added[a.Name]++
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package clash
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Rule 表示 rules 中引用 rule-provider 的一条规则，如 RULE-SET,direct,DIRECT
+type Rule struct {
+	Provider  string
+	Policy    string
+	NoResolve bool   // 仅对 ipcidr/classical 规则集有意义，匹配时不解析域名
+	Line      string // 不引用规则集的规则，如 GEOIP,CN,DIRECT，Provider 为空时原样输出
+}
+
+// Template 描述生成 Clash 配置所需的信息
+type Template struct {
+	BaseURL   string // 生成文件所在的地址，rule-provider 的 url 为 BaseURL/<name>.yaml
+	Interval  int    // rule-provider 的更新间隔，单位为秒
+	Prelude   []byte // 原样输出在最前面的内容，通常为 proxies 与 proxy-groups
+	Providers []byte // 原样输出在 rule-providers 下的自定义规则集，其中的规则集不再生成
+	Rules     []Rule // 按顺序输出的规则
+	Final     string // MATCH 规则使用的策略，为空时不输出
+}
+
+// Generate 根据模板输出完整的 rule-providers 与 rules，behaviors 为规则集名称到 behavior 的映射。
+// 模板中引用了既未生成、也未在 Providers 中声明的规则集时返回错误
+func Generate(w io.Writer, t *Template, behaviors map[string]string) error {
+	custom, err := ParseConfig(strings.NewReader("rule-providers:\n" + string(t.Providers)))
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range t.Rules {
+		if rule.Provider == "" {
+			continue
+		}
+
+		if _, ok := behaviors[rule.Provider]; !ok && custom.Providers[rule.Provider] == nil {
+			return fmt.Errorf("rule-provider %s not found", rule.Provider)
+		}
+	}
+
+	b := &strings.Builder{}
+
+	if len(t.Prelude) > 0 {
+		b.Write(t.Prelude)
+
+		if t.Prelude[len(t.Prelude)-1] != '\n' {
+			b.WriteString("\n")
+		}
+	}
+
+	b.WriteString("rule-providers:\n")
+	b.Write(t.Providers)
+
+	written := map[string]bool{}
+
+	for _, rule := range t.Rules {
+		if rule.Provider == "" || written[rule.Provider] || custom.Providers[rule.Provider] != nil {
+			continue
+		}
+
+		written[rule.Provider] = true
+
+		baseURL := strings.TrimSuffix(t.BaseURL, "/")
+
+		b.WriteString(fmt.Sprintf("  %s:\n", quoteKey(rule.Provider)))
+		b.WriteString("    type: http\n")
+		b.WriteString(fmt.Sprintf("    behavior: %s\n", behaviors[rule.Provider]))
+		b.WriteString(fmt.Sprintf("    url: \"%s/%s.yaml\"\n", baseURL, rule.Provider))
+		b.WriteString(fmt.Sprintf("    path: \"./ruleset/%s.yaml\"\n", rule.Provider))
+		b.WriteString(fmt.Sprintf("    interval: %d\n", t.Interval))
+	}
+
+	b.WriteString("rules:\n")
+
+	for _, rule := range t.Rules {
+		if rule.Provider == "" {
+			b.WriteString(fmt.Sprintf("  - %s\n", rule.Line))
+
+			continue
+		}
+
+		line := fmt.Sprintf("RULE-SET,%s,%s", rule.Provider, rule.Policy)
+		if rule.NoResolve {
+			line += ",no-resolve"
+		}
+
+		b.WriteString(fmt.Sprintf("  - %s\n", line))
+	}
+
+	if t.Final != "" {
+		b.WriteString(fmt.Sprintf("  - MATCH,%s\n", t.Final))
+	}
+
+	_, err = io.WriteString(w, b.String())
+
+	return err
+}
+
+// quoteKey 为包含 yaml 特殊字符的规则集名称加上引号，如 geolocation-!cn@cn
+func quoteKey(key string) string {
+	if strings.ContainsAny(key, "!@:#&*") {
+		return "\"" + key + "\""
+	}
+
+	return key
+}
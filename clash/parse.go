@@ -0,0 +1,127 @@
+package clash
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// ProviderConfig 为 rule-providers 中的一项
+type ProviderConfig struct {
+	Name     string
+	Behavior string
+	URL      string
+	Path     string
+}
+
+// Config 为 Clash 配置中与规则相关的部分
+type Config struct {
+	Providers map[string]*ProviderConfig
+	Rules     []string
+}
+
+// ParseConfig 读取 Clash 配置中的 rule-providers 与 rules。
+// 只支持常见的块格式写法，rule-provider 也可以写为单行的 {key: value} 格式
+func ParseConfig(r io.Reader) (*Config, error) {
+	config := &Config{
+		Providers: map[string]*ProviderConfig{},
+	}
+
+	section := ""
+	providerIndent := -1
+
+	var current *ProviderConfig
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		if indent == 0 && !strings.HasPrefix(trimmed, "-") {
+			key, _ := splitKeyValue(trimmed)
+
+			section = key
+			providerIndent = -1
+			current = nil
+
+			continue
+		}
+
+		switch section {
+		case "rules":
+			if strings.HasPrefix(trimmed, "- ") {
+				config.Rules = append(config.Rules, unquote(strings.TrimSpace(trimmed[len("- "):])))
+			}
+		case "rule-providers":
+			if providerIndent < 0 {
+				providerIndent = indent
+			}
+
+			key, value := splitKeyValue(trimmed)
+
+			if indent == providerIndent {
+				current = &ProviderConfig{Name: key}
+				config.Providers[key] = current
+
+				if strings.HasPrefix(value, "{") && strings.HasSuffix(value, "}") {
+					for _, field := range strings.Split(value[1:len(value)-1], ",") {
+						current.set(splitKeyValue(strings.TrimSpace(field)))
+					}
+				}
+
+				continue
+			}
+
+			if current != nil {
+				current.set(key, value)
+			}
+		}
+	}
+
+	return config, scanner.Err()
+}
+
+func (p *ProviderConfig) set(key, value string) {
+	switch key {
+	case "behavior":
+		p.Behavior = value
+	case "url":
+		p.URL = value
+	case "path":
+		p.Path = value
+	}
+}
+
+// splitKeyValue 拆分 "key: value"，并去掉两侧的引号与行尾注释
+func splitKeyValue(line string) (string, string) {
+	key, value, _ := strings.Cut(line, ":")
+
+	// url 中包含冒号，只有冒号后跟空格或位于行尾时才是分隔符
+	if i := strings.Index(line, ": "); i >= 0 {
+		key, value = line[:i], line[i+len(": "):]
+	} else if strings.HasSuffix(line, ":") {
+		key, value = line[:len(line)-1], ""
+	}
+
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "\"") && !strings.HasPrefix(value, "'") {
+		value = strings.TrimSpace(strings.SplitN(value, " #", 2)[0])
+	}
+
+	return unquote(strings.TrimSpace(key)), unquote(value)
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+
+	return s
+}
@@ -0,0 +1,85 @@
+package clash
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// ParseTemplate 读取 Clash 配置模板 data，模板中 rules 与 rule-providers 以外的内容原样作为 Prelude。
+// 模板中有 rules 时以其替换 defaults 的规则：RULE-SET 规则按顺序引用规则集，MATCH 规则作为 Final，其余规则原样保留；
+// 没有 rules 时沿用 defaults 的 Rules 与 Final。模板的 rule-providers 原样输出，其中声明的规则集不再生成
+func ParseTemplate(data []byte, defaults *Template) (*Template, error) {
+	config, err := ParseConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	t := *defaults
+	t.Prelude, t.Providers = splitTemplate(data)
+
+	if len(config.Rules) == 0 {
+		return &t, nil
+	}
+
+	t.Rules, t.Final = nil, ""
+
+	for _, line := range config.Rules {
+		line = unquote(strings.TrimSpace(strings.SplitN(line, " #", 2)[0]))
+
+		fields := strings.Split(line, ",")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+
+		switch strings.ToUpper(fields[0]) {
+		case "RULE-SET":
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("invalid rule %q", line)
+			}
+
+			t.Rules = append(t.Rules, Rule{
+				Provider:  fields[1],
+				Policy:    fields[2],
+				NoResolve: len(fields) > 3 && fields[3] == "no-resolve",
+			})
+		case "MATCH":
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("invalid rule %q", line)
+			}
+
+			t.Final = fields[1]
+		default:
+			t.Rules = append(t.Rules, Rule{Line: line})
+		}
+	}
+
+	return &t, nil
+}
+
+// splitTemplate 将模板拆分为 rules 与 rule-providers 以外的顶层内容，以及 rule-providers 下的条目
+func splitTemplate(data []byte) (prelude []byte, providers []byte) {
+	section := ""
+
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") && line[0] != ' ' && line[0] != '\t' && line[0] != '-' {
+			section, _ = splitKeyValue(strings.TrimRight(trimmed, " \t\r"))
+
+			if section == "rules" || section == "rule-providers" {
+				continue
+			}
+		}
+
+		switch section {
+		case "rules":
+		case "rule-providers":
+			providers = append(providers, line...)
+		default:
+			prelude = append(prelude, line...)
+		}
+	}
+
+	return prelude, providers
+}
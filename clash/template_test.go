@@ -0,0 +1,116 @@
+package clash
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+var defaultTemplate = &Template{
+	BaseURL:  "https://example.com/rules",
+	Interval: 86400,
+	Rules:    []Rule{{Provider: "proxy", Policy: "PROXY"}},
+	Final:    "PROXY",
+}
+
+func TestParseTemplate(t *testing.T) {
+	data := []byte(`proxies:
+  - name: a
+    type: socks5
+proxy-groups:
+  - name: Auto
+    type: select
+    proxies: [a]
+rule-providers:
+  mine:
+    type: file
+    behavior: domain
+    path: ./mine.yaml
+rules:
+  - RULE-SET,mine,DIRECT
+  - RULE-SET,adv,REJECT
+  - 'DOMAIN-SUFFIX,example.org,Auto' # comment
+  - RULE-SET,cncidr,DIRECT,no-resolve
+  - MATCH,Auto
+`)
+
+	template, err := ParseTemplate(data, defaultTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantRules := []Rule{
+		{Provider: "mine", Policy: "DIRECT"},
+		{Provider: "adv", Policy: "REJECT"},
+		{Line: "DOMAIN-SUFFIX,example.org,Auto"},
+		{Provider: "cncidr", Policy: "DIRECT", NoResolve: true},
+	}
+
+	if !reflect.DeepEqual(template.Rules, wantRules) {
+		t.Errorf("rules = %+v, want %+v", template.Rules, wantRules)
+	}
+
+	if template.Final != "Auto" {
+		t.Errorf("final = %s, want Auto", template.Final)
+	}
+
+	wantPrelude := "proxies:\n  - name: a\n    type: socks5\nproxy-groups:\n  - name: Auto\n    type: select\n    proxies: [a]\n"
+	if string(template.Prelude) != wantPrelude {
+		t.Errorf("prelude = %q, want %q", template.Prelude, wantPrelude)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := Generate(buf, template, map[string]string{"adv": "domain", "cncidr": "ipcidr"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := wantPrelude + `rule-providers:
+  mine:
+    type: file
+    behavior: domain
+    path: ./mine.yaml
+  adv:
+    type: http
+    behavior: domain
+    url: "https://example.com/rules/adv.yaml"
+    path: "./ruleset/adv.yaml"
+    interval: 86400
+  cncidr:
+    type: http
+    behavior: ipcidr
+    url: "https://example.com/rules/cncidr.yaml"
+    path: "./ruleset/cncidr.yaml"
+    interval: 86400
+rules:
+  - RULE-SET,mine,DIRECT
+  - RULE-SET,adv,REJECT
+  - DOMAIN-SUFFIX,example.org,Auto
+  - RULE-SET,cncidr,DIRECT,no-resolve
+  - MATCH,Auto
+`
+
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestParseTemplateDefaults(t *testing.T) {
+	template, err := ParseTemplate([]byte("proxies: []\n"), defaultTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(template.Rules, defaultTemplate.Rules) || template.Final != defaultTemplate.Final {
+		t.Errorf("got %+v %s, want the defaults", template.Rules, template.Final)
+	}
+
+	if string(template.Prelude) != "proxies: []\n" {
+		t.Errorf("prelude = %q", template.Prelude)
+	}
+}
+
+func TestGenerateMissingProvider(t *testing.T) {
+	if err := Generate(&bytes.Buffer{}, defaultTemplate, map[string]string{}); err == nil {
+		t.Error("expected an error for a missing provider")
+	}
+}
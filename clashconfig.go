@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/kr328/domains2providers/clash"
+	"github.com/kr328/domains2providers/manifest"
+	"github.com/kr328/domains2providers/output"
+)
+
+func runConfig(args []string) error {
+	flags := newFlagSet(stageConfig)
+	baseURL := flags.String("base-url", defaultBaseURL, "base url of generated files in clash config")
+	template := flags.String("template", "", "clash config template with proxies and proxy-groups, its rules replace clashRules and clashFinal")
+
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+
+		return errUsage
+	}
+
+	dir := flags.Arg(0)
+
+	index, err := manifest.Load(path.Join(dir, manifest.FileName))
+	if err != nil {
+		return fmt.Errorf("load index: %w", err)
+	}
+
+	t := &clash.Template{
+		BaseURL:  *baseURL,
+		Interval: clashInterval,
+		Rules:    clashRules,
+		Final:    clashFinal,
+	}
+
+	if *template != "" {
+		prelude, err := os.ReadFile(*template)
+		if err != nil {
+			return err
+		}
+
+		t, err = clash.ParseTemplate(prelude, t)
+		if err != nil {
+			return fmt.Errorf("parse template: %w", err)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+
+	if err := clash.Generate(buf, t, index.Behaviors()); err != nil {
+		return err
+	}
+
+	if err := output.WriteFile(path.Join(dir, clashConfigName), buf.Bytes()); err != nil {
+		return err
+	}
+
+	entry := manifest.NewEntry(clashConfigName, buf.Bytes())
+	entry.Name = "clash-config"
+	entry.Format = "clash-config"
+	entry.Count = len(t.Rules)
+	entry.Stage = stageConfig
+
+	return updateIndex(dir, []*manifest.Entry{entry}, nil, "")
+}
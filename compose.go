@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kr328/domains2providers/manifest"
+	"github.com/kr328/domains2providers/output"
+	"github.com/kr328/domains2providers/trie"
+)
+
+// maxComposeResults 为缓存的组合结果数量上限，超过时清空缓存
+const maxComposeResults = 256
+
+// publishDomains 在 serve 中接收 generate 与 raw 写入索引的 domain 规则集，file 为其 clash 文件，sha256 为文件的 sha256
+var publishDomains func(file, sha256 string, rules []string)
+
+// publishedDomains 为同一进程中生成的规则集的规则，sha256 与索引一致时 compose 直接使用，无需重新读取发布的文件
+type publishedDomains struct {
+	sha256 string
+	rules  []string
+}
+
+// composeProvider 为可以参与组合的 domain 规则集
+type composeProvider struct {
+	rules   []string
+	domains *trie.Trie
+}
+
+// composeCache 缓存 compose 接口使用的规则集与组合结果，index.json 变化时失效
+type composeCache struct {
+	indexModTime time.Time
+	entries      map[string]*manifest.Entry  // 输出名到 clash 格式的索引项
+	providers    map[string]*composeProvider // 已加载的规则集
+	results      map[string]*servedFile
+}
+
+// composeError 表示请求参数错误
+type composeError struct {
+	message string
+}
+
+func (e *composeError) Error() string {
+	return e.message
+}
+
+// publish 记录生成的 domain 规则集，供 compose 使用
+func (s *server) publish(file, sha256 string, rules []string) {
+	s.composeMu.Lock()
+	defer s.composeMu.Unlock()
+
+	s.published[file] = publishedDomains{sha256: sha256, rules: rules}
+}
+
+// serveCompose 根据 include、exclude 与 format 参数按需组合输出目录中的 domain 规则集：
+// 合并 include 中的规则集，去掉被 exclude 中的规则集完整覆盖的规则
+func (s *server) serveCompose(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	query := r.URL.Query()
+
+	include := splitList(query.Get("include"))
+	exclude := splitList(query.Get("exclude"))
+
+	format := query.Get("format")
+	if format == "" {
+		format = "clash"
+	}
+
+	file, extension, err := s.compose(include, exclude, format)
+
+	var badRequest *composeError
+	if errors.As(err, &badRequest) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	} else if err != nil {
+		slog.Error("compose", "include", include, "exclude", exclude, "format", format, "err", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+
+		return
+	}
+
+	serveContent(w, r, "compose"+extension, file)
+}
+
+// compose 返回组合结果与其格式的扩展名，结果按参数缓存
+func (s *server) compose(include, exclude []string, format string) (*servedFile, string, error) {
+	if !contains(formats, format) {
+		return nil, "", &composeError{fmt.Sprintf("unsupported format %s, supported: %s", format, strings.Join(formats, ", "))}
+	}
+
+	if len(include) == 0 {
+		return nil, "", &composeError{"include is required"}
+	}
+
+	writer := writersOf("", output.Domain, []string{format})[0]
+
+	include = append([]string{}, include...)
+	exclude = append([]string{}, exclude...)
+
+	sort.Strings(include)
+	sort.Strings(exclude)
+
+	key := strings.Join(include, ",") + "|" + strings.Join(exclude, ",") + "|" + format
+
+	s.composeMu.Lock()
+	defer s.composeMu.Unlock()
+
+	if err := s.refreshCompose(); err != nil {
+		return nil, "", err
+	}
+
+	if file, ok := s.composed.results[key]; ok {
+		return file, writer.Extension(), nil
+	}
+
+	domains := trie.New()
+
+	for _, name := range include {
+		p, err := s.composeProvider(name)
+		if err != nil {
+			return nil, "", err
+		}
+
+		for _, rule := range p.rules {
+			_ = domains.Add(rule)
+		}
+	}
+
+	var excluded []*trie.Trie
+
+	for _, name := range exclude {
+		p, err := s.composeProvider(name)
+		if err != nil {
+			return nil, "", err
+		}
+
+		excluded = append(excluded, p.domains)
+	}
+
+	var rules []string
+
+	for _, rule := range domains.Dump() {
+		if !coveredByTries(excluded, rule) {
+			rules = append(rules, rule)
+		}
+	}
+
+	sort.Strings(rules)
+
+	buf := &bytes.Buffer{}
+
+	if err := writer.Write(buf, output.Domain, rules); errors.Is(err, output.ErrEmptyRuleset) {
+		return nil, "", &composeError{"no rules left to compose"}
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	file := newServedFile(buf.Bytes(), s.composed.indexModTime)
+
+	if len(s.composed.results) >= maxComposeResults {
+		s.composed.results = map[string]*servedFile{}
+	}
+
+	s.composed.results[key] = file
+
+	return file, writer.Extension(), nil
+}
+
+// coveredByTries 判断 domain 规则 rule 是否被 tries 中的某个规则集完整覆盖，后缀规则只能被后缀规则覆盖
+func coveredByTries(tries []*trie.Trie, rule string) bool {
+	domain := strings.TrimPrefix(rule, "+.")
+	suffix := domain != rule
+
+	for _, t := range tries {
+		if by, ok := t.Match(domain); ok && (!suffix || strings.HasPrefix(by, "+.")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// refreshCompose 在 index.json 变化后重新读取索引，清空加载的规则集与缓存的结果，并丢弃与索引不一致的生成结果
+func (s *server) refreshCompose() error {
+	info, err := os.Stat(path.Join(s.dir, manifest.FileName))
+	if err != nil {
+		return err
+	}
+
+	if s.composed.entries != nil && s.composed.indexModTime.Equal(info.ModTime()) {
+		return nil
+	}
+
+	index, err := manifest.Load(path.Join(s.dir, manifest.FileName))
+	if err != nil {
+		return fmt.Errorf("load index: %w", err)
+	}
+
+	s.composed = composeCache{
+		indexModTime: info.ModTime(),
+		entries:      map[string]*manifest.Entry{},
+		providers:    map[string]*composeProvider{},
+		results:      map[string]*servedFile{},
+	}
+
+	files := map[string]string{}
+
+	for _, entry := range index.Files {
+		if entry.Format != "clash" {
+			continue
+		}
+
+		s.composed.entries[strings.TrimSuffix(entry.File, output.Clash{}.Extension())] = entry
+		files[entry.File] = entry.SHA256
+	}
+
+	for file, published := range s.published {
+		if files[file] != published.sha256 {
+			delete(s.published, file)
+		}
+	}
+
+	return nil
+}
+
+// composeProvider 返回可以参与组合的 domain 规则集。
+// 优先使用同一进程生成的规则，没有时（如 -interval 0 或规则集沿用了上次的输出）读取发布的文件
+func (s *server) composeProvider(name string) (*composeProvider, error) {
+	if p, ok := s.composed.providers[name]; ok {
+		return p, nil
+	}
+
+	entry, ok := s.composed.entries[name]
+	if !ok {
+		return nil, &composeError{fmt.Sprintf("unknown provider %s", name)}
+	}
+
+	if entry.Behavior != output.Domain {
+		return nil, &composeError{fmt.Sprintf("provider %s is %s, only domain providers can be composed", name, entry.Behavior)}
+	}
+
+	var rules []string
+
+	if published, ok := s.published[entry.File]; ok && published.sha256 == entry.SHA256 {
+		rules = published.rules
+	} else {
+		file, err := os.Open(path.Join(s.dir, entry.File))
+		if err != nil {
+			return nil, err
+		}
+
+		rules, err = output.ReadClash(file)
+		_ = file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", entry.File, err)
+		}
+	}
+
+	p := &composeProvider{rules: rules, domains: trie.New()}
+	for _, rule := range rules {
+		_ = p.domains.Add(rule)
+	}
+
+	s.composed.providers[name] = p
+
+	return p, nil
+}
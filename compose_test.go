@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kr328/domains2providers/output"
+)
+
+// newComposeServer 在 dir 中生成 google 与 ads，生成的规则通过 publishDomains 交给返回的 server
+func newComposeServer(t *testing.T, dir string) *server {
+	s := &server{dir: dir, files: map[string]*servedFile{}, published: map[string]publishedDomains{}}
+
+	defer func(saved func(string, string, []string)) { publishDomains = saved }(publishDomains)
+	publishDomains = s.publish
+
+	g := newTestGenerator(dir)
+	g.write(&provider{Name: "google", Behavior: output.Domain, Rules: []string{"+.google.com", "+.youtube.com", "+.doubleclick.net", "ads.example.com"}})
+	g.write(&provider{Name: "ads", Behavior: output.Domain, Rules: []string{"+.doubleclick.net", "+.example.com", "youtube.com"}})
+	g.write(&provider{Name: "lan", Behavior: output.IPCIDR, Rules: []string{"1.0.1.0/24"}})
+	if err := g.finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestCompose(t *testing.T) {
+	dir := t.TempDir()
+	s := newComposeServer(t, dir)
+
+	if len(s.published) != 2 {
+		t.Fatalf("published %d providers, want google and ads", len(s.published))
+	}
+
+	// 生成的规则可用时不重新读取发布的文件
+	if err := os.WriteFile(filepath.Join(dir, "google.yaml"), []byte("broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "payload:\n  - \"+.google.com\"\n  - \"+.youtube.com\"\n"
+
+	file, extension, err := s.compose([]string{"google"}, []string{"ads"}, "clash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if extension != ".yaml" || string(file.content) != want {
+		t.Errorf("compose = %s %q, want .yaml %q", extension, file.content, want)
+	}
+
+	file, extension, err = s.compose([]string{"google"}, []string{"ads"}, "mrs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if extension != ".mrs" || !bytes.HasPrefix(file.content, []byte{0x28, 0xb5, 0x2f, 0xfd}) {
+		t.Errorf("compose mrs = %s %x, want a zstd frame", extension, file.content)
+	}
+
+	for _, test := range []struct {
+		include, exclude []string
+		format           string
+	}{
+		{[]string{"unknown"}, nil, "clash"},
+		{[]string{"lan"}, nil, "clash"},
+		{[]string{"google"}, nil, "unknown"},
+		{nil, []string{"ads"}, "clash"},
+	} {
+		if _, _, err := s.compose(test.include, test.exclude, test.format); err == nil {
+			t.Errorf("compose(%v, %v, %s) succeeded, want an error", test.include, test.exclude, test.format)
+		}
+	}
+}
+
+func TestComposeReadsPublishedFiles(t *testing.T) {
+	dir := t.TempDir()
+	s := newComposeServer(t, dir)
+
+	// 没有同一进程生成的规则时读取发布的文件
+	s.published = map[string]publishedDomains{}
+
+	file, _, err := s.compose([]string{"ads"}, []string{"google"}, "clash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "payload:\n  - \"+.example.com\"\n"; string(file.content) != want {
+		t.Errorf("compose = %q, want %q", file.content, want)
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kr328/domains2providers/clash"
+	"github.com/kr328/domains2providers/output"
+	"github.com/kr328/domains2providers/raw"
+)
+
+const (
+	defaultBaseURL  = "https://raw.githubusercontent.com/gamesofts/V2rayDomains2Clash/generated"
+	clashConfigName = "clash-config.yaml"
+	clashInterval   = 86400
+	clashFinal      = "PROXY" // 模板没有 rules 时 MATCH 规则使用的策略
+)
+
+// aggregate 描述由多个 domain-list-community 规则集合并而成的输出，被后缀覆盖的子域名会被合并
+type aggregate struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"` // 支持 name、name@tag 与 @tag，含义与 classicalOutputs 相同
+}
+
+// providersConfig 为 providers.json 的内容，声明原始规则、聚合、classical 输出、DNS 输出、回归保护与冲突检查
+type providersConfig struct {
+	Raws             []*raw.Raw  `json:"raws"`
+	Aggregates       []aggregate `json:"aggregates"`
+	ClassicalOutputs []string    `json:"classical_outputs"`
+	DNS              []dnsOutput `json:"dns"`
+	Guards           []*guard    `json:"guards"`
+	ConflictPairs    [][2]string `json:"conflict_pairs"`
+}
+
+// defaultProviders 为仓库中的 providers.json，未指定 -providers 时使用
+//
+//go:embed providers.json
+var defaultProviders []byte
+
+var (
+	raws       []*raw.Raw  // 需要从网络下载的原始规则，可以按需添加 BlacklistUrl
+	aggregates []aggregate // 由多个 domain-list-community 规则集合并而成的输出
+	dnsOutputs []dnsOutput // 需要额外输出 DNS 服务器配置的规则集
+	guards     []*guard    // 按顺序匹配，每个规则集只使用第一个匹配的 guard
+
+	// conflictPairs 为应当互不相交的规则集，conflicts 命令检查它们之间匹配相同域名的规则
+	conflictPairs [][2]string
+
+	// classicalOutputs 声明以 classical 行为输出的 domain-list-community 规则集，classical 会保留 keyword 与 regexp 规则。
+	// 支持 name（规则集本身）、name@tag（规则集的某个标签）与 @tag（所有规则集的某个标签）。
+	// 匹配的规则集不再输出 domain 行为的同名文件，会影响已有的订阅，因此默认为空，如需要可添加 "category-porn"
+	classicalOutputs []string
+)
+
+func init() {
+	if err := parseProviders(defaultProviders); err != nil {
+		panic(fmt.Sprintf("embedded providers.json: %v", err))
+	}
+}
+
+// loadProviders 读取 -providers 指定的文件，替换 raws、aggregates、classicalOutputs、dnsOutputs、guards 与 conflictPairs
+func loadProviders(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	if err := parseProviders(data); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	return nil
+}
+
+// parseProviders 解析并校验 providers.json 的内容，通过校验后替换 raws、aggregates、classicalOutputs、dnsOutputs、guards 与 conflictPairs
+func parseProviders(data []byte) error {
+	config := &providersConfig{}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(config); err != nil {
+		return err
+	}
+
+	names := map[string]bool{}
+
+	for _, r := range config.Raws {
+		switch {
+		case r.Name == "":
+			return errors.New("raw without name")
+		case names[r.Name]:
+			return fmt.Errorf("duplicate raw %s", r.Name)
+		case r.Behavior != output.Domain && r.Behavior != output.IPCIDR && r.Behavior != output.Classical:
+			return fmt.Errorf("raw %s: unknown behavior %q", r.Name, r.Behavior)
+		case len(r.SourceUrl) == 0:
+			return fmt.Errorf("raw %s: no source_url", r.Name)
+		}
+
+		names[r.Name] = true
+	}
+
+	for _, a := range config.Aggregates {
+		if a.Name == "" || len(a.Members) == 0 {
+			return fmt.Errorf("aggregate %q: name and members are required", a.Name)
+		}
+	}
+
+	for _, pattern := range config.ClassicalOutputs {
+		if pattern == "" || pattern == "@" {
+			return errors.New("empty classical output")
+		}
+	}
+
+	for _, o := range config.DNS {
+		if o.Name == "" {
+			return errors.New("dns output without name")
+		}
+	}
+
+	for _, g := range config.Guards {
+		switch {
+		case g.Pattern == "":
+			return errors.New("guard without pattern")
+		case g.Action != guardAbort && g.Action != guardSkip:
+			return fmt.Errorf("guard %s: unknown action %q", g.Pattern, g.Action)
+		case g.MinEntries < 0 || g.MaxChange < 0:
+			return fmt.Errorf("guard %s: min_entries and max_change must not be negative", g.Pattern)
+		}
+	}
+
+	for _, pair := range config.ConflictPairs {
+		if pair[0] == "" || pair[1] == "" {
+			return fmt.Errorf("conflict pair %s:%s: both rulesets are required", pair[0], pair[1])
+		}
+	}
+
+	raws, aggregates, dnsOutputs, guards = config.Raws, config.Aggregates, config.DNS, config.Guards
+	classicalOutputs, conflictPairs = config.ClassicalOutputs, config.ConflictPairs
+
+	return nil
+}
+
+const (
+	guardAbort = "abort" // 不写入该规则集，并使本次运行失败
+	guardSkip  = "skip"  // 保留上次输出的文件
+)
+
+// guard 为规则集的回归保护：条数少于 MinEntries，或与上次输出相比增减超过 MaxChange 百分比时按 Action 处理
+type guard struct {
+	Pattern    string  `json:"pattern"` // 规则集，格式同 -only
+	MinEntries int     `json:"min_entries,omitempty"`
+	MaxChange  float64 `json:"max_change,omitempty"` // 为 0 时不检查
+	Action     string  `json:"action"`
+}
+
+// dnsOutput 描述需要额外输出 DNS 服务器配置的规则集
+type dnsOutput struct {
+	Name   string `json:"name"`             // 规则集输出名称，如 direct、ads、google@cn
+	Server string `json:"server,omitempty"` // AdGuard Home/dnsmasq/Unbound 使用的上游 DNS 服务器，为空时输出屏蔽规则
+	Group  string `json:"group,omitempty"`  // SmartDNS 使用的服务器组，为空时输出屏蔽规则
+}
+
+// isClassical 判断规则集 name 的 tag 是否声明为 classical 输出
+func isClassical(name, tag string) bool {
+	for _, pattern := range classicalOutputs {
+		if matchOutput(pattern, name, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasClassical 判断规则集 name 是否可能存在 classical 输出
+func hasClassical(name string) bool {
+	for _, pattern := range classicalOutputs {
+		patternName, _, _ := strings.Cut(pattern, "@")
+		if patternName == "" || patternName == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchOutput 判断 pattern（name、name@tag、@tag 或 name@*）是否匹配规则集 name 的 tag
+func matchOutput(pattern, name, tag string) bool {
+	patternName, patternTag, _ := strings.Cut(pattern, "@")
+	if patternName == "" {
+		return patternTag == tag
+	}
+
+	return patternName == name && (patternTag == tag || patternTag == "*")
+}
+
+// formats 为支持的全部输出格式，mrs 只在 -formats 中指定时输出
+var formats = []string{"clash", "adguard", "dnsmasq", "smartdns", "unbound", "mrs"}
+
+// dnsFormats 为 dnsOutputs 中的规则集默认输出的 DNS 格式
+var dnsFormats = []string{"adguard", "dnsmasq", "smartdns", "unbound"}
+
+// writersOf 返回规则集需要输出的格式对应的 Writer。
+// selected 为空时输出 clash 格式，以及 dnsOutputs 中声明的 DNS 格式；ipcidr 规则集不输出 DNS 格式，mrs 只用于 domain 规则集
+func writersOf(name, behavior string, selected []string) []output.Writer {
+	dns := dnsOutput{}
+	configured := false
+
+	for _, o := range dnsOutputs {
+		if o.Name == name {
+			dns = o
+			configured = true
+		}
+	}
+
+	if len(selected) == 0 {
+		if configured {
+			selected = append([]string{"clash"}, dnsFormats...)
+		} else {
+			selected = []string{"clash"}
+		}
+	}
+
+	var writers []output.Writer
+
+	for _, format := range selected {
+		if format != "clash" && behavior == output.IPCIDR || format == "mrs" && behavior != output.Domain {
+			continue
+		}
+
+		switch format {
+		case "clash":
+			writers = append(writers, output.Clash{})
+		case "adguard":
+			writers = append(writers, &output.AdGuardHome{Upstream: dns.Server})
+		case "dnsmasq":
+			writers = append(writers, &output.Dnsmasq{Upstream: dns.Server})
+		case "smartdns":
+			writers = append(writers, &output.SmartDNS{Group: dns.Group})
+		case "unbound":
+			writers = append(writers, &output.Unbound{Upstream: dns.Server})
+		case "mrs":
+			writers = append(writers, output.Mrs{})
+		}
+	}
+
+	return writers
+}
+
+// clashRules 为生成的 Clash 配置中按顺序引用的规则集与策略，与 clashFinal 一样只在 -template 没有 rules 时使用
+var clashRules = []clash.Rule{
+	{Provider: "adv", Policy: "REJECT"},
+	{Provider: "ntp", Policy: "DIRECT"},
+	{Provider: "apple-cert", Policy: "DIRECT"},
+	{Provider: "media", Policy: "PROXY"},
+	{Provider: "proxy", Policy: "PROXY"},
+	{Provider: "direct", Policy: "DIRECT"},
+	{Provider: "lancidr", Policy: "DIRECT", NoResolve: true},
+	{Provider: "cncidr", Policy: "DIRECT", NoResolve: true},
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseProvidersGuards(t *testing.T) {
+	defer func() {
+		if err := parseProviders(defaultProviders); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	if err := parseProviders([]byte(`{"guards": [{"pattern": "direct", "min_entries": 10, "max_change": 50, "action": "skip"}]}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []*guard{{Pattern: "direct", MinEntries: 10, MaxChange: 50, Action: guardSkip}}; !reflect.DeepEqual(guards, want) {
+		t.Errorf("guards = %+v, want %+v", guards, want)
+	}
+
+	invalid := []string{
+		`{"guards": [{"min_entries": 10, "action": "skip"}]}`,
+		`{"guards": [{"pattern": "direct", "action": "drop"}]}`,
+		`{"guards": [{"pattern": "direct", "min_entries": -1, "action": "abort"}]}`,
+		`{"guards": [{"pattern": "direct", "max_change": -5, "action": "abort"}]}`,
+		`{"guards": [{"pattern": "direct", "action": "skip", "minEntries": 10}]}`,
+	}
+
+	for _, data := range invalid {
+		if err := parseProviders([]byte(data)); err == nil {
+			t.Errorf("%s accepted", data)
+		}
+	}
+}
+
+func TestParseProvidersClassicalOutputs(t *testing.T) {
+	defer func() {
+		if err := parseProviders(defaultProviders); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	if err := parseProviders([]byte(`{"classical_outputs": ["category-porn", "@cn"]}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !isClassical("category-porn", "") || !isClassical("google", "cn") || isClassical("google", "") {
+		t.Errorf("classical outputs = %v", classicalOutputs)
+	}
+
+	if err := parseProviders([]byte(`{"classical_outputs": [""]}`)); err == nil {
+		t.Error("empty classical output accepted")
+	}
+}
+
+func TestParseProvidersConflictPairs(t *testing.T) {
+	defer func() {
+		if err := parseProviders(defaultProviders); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	if err := parseProviders([]byte(`{"conflict_pairs": [["direct", "ads"]]}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := [][2]string{{"direct", "ads"}}; !reflect.DeepEqual(conflictPairs, want) {
+		t.Errorf("conflict pairs = %v, want %v", conflictPairs, want)
+	}
+
+	if err := parseProviders([]byte(`{"conflict_pairs": [["direct", ""]]}`)); err == nil {
+		t.Error("conflict pair without right side accepted")
+	}
+}
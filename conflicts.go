@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/kr328/domains2providers/match"
+	"github.com/kr328/domains2providers/output"
+	"github.com/kr328/domains2providers/raw"
+)
+
+// conflict 表示两个规则集中匹配了相同域名的一对规则
+type conflict struct {
+	Left, Right string
+}
+
+func runConflicts(args []string) error {
+	flags := newFlagSet("conflicts")
+	pairs := flags.String("pairs", "", "comma separated pairs to check as a:b, defaults to conflict_pairs of providers.json")
+	dir := flags.String("output", "", "output path to load providers that are not raw sources")
+	fail := flags.Bool("fail", false, "exit with an error if any conflict is found")
+
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+
+		return errUsage
+	}
+
+	checks := conflictPairs
+	if *pairs != "" {
+		checks = nil
+
+		for _, pair := range splitList(*pairs) {
+			left, right, ok := strings.Cut(pair, ":")
+			if !ok {
+				return fmt.Errorf("invalid pair %s", pair)
+			}
+
+			checks = append(checks, [2]string{left, right})
+		}
+	}
+
+	providers, origins, err := loadConflictProviders(checks, *dir)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	total := 0
+
+	for _, pair := range checks {
+		left, right := providers[pair[0]], providers[pair[1]]
+
+		conflicts := findConflicts(left, right)
+		total += len(conflicts)
+
+		_, _ = fmt.Fprintf(w, "%s <-> %s: %d conflicts\n", pair[0], pair[1], len(conflicts))
+
+		for _, c := range conflicts {
+			_, _ = fmt.Fprintf(w, "  %s%s <-> %s%s\n",
+				c.Left, formatOrigins(origins[pair[0]][c.Left]),
+				c.Right, formatOrigins(origins[pair[1]][c.Right]))
+		}
+	}
+
+	if *fail && total > 0 {
+		return fmt.Errorf("%d conflicts found", total)
+	}
+
+	return nil
+}
+
+// loadConflictProviders 读取检查所需的规则集：原始规则重新下载以得到每条规则的来源，其余规则集从输出目录读取
+func loadConflictProviders(checks [][2]string, dir string) (map[string]*provider, map[string]map[string][]string, error) {
+	needed := map[string]bool{}
+	for _, pair := range checks {
+		needed[pair[0]] = true
+		needed[pair[1]] = true
+	}
+
+	var selected []*raw.Raw
+	for _, r := range raws {
+		if needed[r.Name] {
+			selected = append(selected, r)
+		}
+	}
+
+	providers := map[string]*provider{}
+	origins := map[string]map[string][]string{}
+
+	if len(selected) > 0 {
+		ruleSets, failures := raw.LoadRawSources(selected, nil)
+		if len(failures) > 0 {
+			return nil, nil, fmt.Errorf("load raw resources: %w", failures[0])
+		}
+
+		for _, r := range ruleSets {
+			providers[r.Name] = &provider{Name: r.Name, Behavior: r.Behavior, Rules: r.Rules}
+			origins[r.Name] = r.Origins
+		}
+	}
+
+	if dir != "" {
+		loaded, err := loadOutputProviders(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, p := range loaded {
+			if needed[p.OutputName()] && providers[p.OutputName()] == nil {
+				providers[p.OutputName()] = p
+			}
+		}
+	}
+
+	for name := range needed {
+		if providers[name] == nil {
+			return nil, nil, fmt.Errorf("provider %s not found, use -output for non raw providers", name)
+		}
+	}
+
+	return providers, origins, nil
+}
+
+// findConflicts 找出 left 与 right 中匹配相同域名的规则对，后缀规则覆盖的子域名同样视为冲突
+func findConflicts(left, right *provider) []conflict {
+	leftSet := match.New(left.Behavior, left.Rules)
+	rightSet := match.New(right.Behavior, right.Rules)
+
+	found := map[conflict]bool{}
+
+	for _, rule := range left.Rules {
+		if domain, ok := domainOf(left.Behavior, rule); ok {
+			if matched, ok := rightSet.Match(domain); ok {
+				found[conflict{Left: rule, Right: matched}] = true
+			}
+		}
+	}
+
+	for _, rule := range right.Rules {
+		if domain, ok := domainOf(right.Behavior, rule); ok {
+			if matched, ok := leftSet.Match(domain); ok {
+				found[conflict{Left: matched, Right: rule}] = true
+			}
+		}
+	}
+
+	conflicts := make([]conflict, 0, len(found))
+	for c := range found {
+		conflicts = append(conflicts, c)
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Left != conflicts[j].Left {
+			return conflicts[i].Left < conflicts[j].Left
+		}
+
+		return conflicts[i].Right < conflicts[j].Right
+	})
+
+	return conflicts
+}
+
+// domainOf 返回域名规则匹配的域名，非域名规则返回 false
+func domainOf(behavior, rule string) (string, bool) {
+	switch behavior {
+	case output.Domain:
+		return strings.TrimPrefix(rule, "+."), true
+	case output.Classical:
+		typ, payload, _ := strings.Cut(rule, ",")
+		if typ == "DOMAIN" || typ == "DOMAIN-SUFFIX" {
+			return payload, true
+		}
+	}
+
+	return "", false
+}
+
+func formatOrigins(origins []string) string {
+	if len(origins) == 0 {
+		return ""
+	}
+
+	return " [" + strings.Join(origins, " ") + "]"
+}
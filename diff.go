@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/kr328/domains2providers/dlc"
+	"github.com/kr328/domains2providers/manifest"
+	"github.com/kr328/domains2providers/match"
+	"github.com/kr328/domains2providers/output"
+)
+
+// subsumedEntry 表示被删除但仍被新规则覆盖的条目
+type subsumedEntry struct {
+	Entry string `json:"entry"`
+	By    string `json:"by"`
+}
+
+// providerDiff 为一个规则集在两次生成之间的差异
+type providerDiff struct {
+	Provider string          `json:"provider"`
+	Status   string          `json:"status"` // added、deleted 或 changed
+	Added    []string        `json:"added,omitempty"`
+	Removed  []string        `json:"removed,omitempty"`
+	Subsumed []subsumedEntry `json:"subsumed,omitempty"`
+}
+
+// snapshot 为一次生成的输出文件来源，可以是目录或 git 仓库中的某个提交
+type snapshot interface {
+	List() ([]string, error)
+	Read(file string) ([]byte, error)
+}
+
+type dirSnapshot string
+
+func (d dirSnapshot) List() ([]string, error) {
+	entries, err := os.ReadDir(string(d))
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			files = append(files, entry.Name())
+		}
+	}
+
+	return files, nil
+}
+
+func (d dirSnapshot) Read(file string) ([]byte, error) {
+	return os.ReadFile(path.Join(string(d), file))
+}
+
+// gitSnapshot 读取 git 仓库 Repo 中 Ref 的根目录
+type gitSnapshot struct {
+	Repo string
+	Ref  string
+}
+
+func (g *gitSnapshot) List() ([]string, error) {
+	// -z 以 NUL 分隔且不转义文件名，文件名中可能有空格或需要转义的字符
+	content, err := exec.Command("git", "-C", g.Repo, "ls-tree", "-z", "--name-only", g.Ref).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-tree %s: %w", g.Ref, err)
+	}
+
+	var files []string
+	for _, file := range strings.Split(string(content), "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+
+	return files, nil
+}
+
+func (g *gitSnapshot) Read(file string) ([]byte, error) {
+	content, err := exec.Command("git", "-C", g.Repo, "show", g.Ref+":"+file).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git show %s:%s: %w", g.Ref, file, err)
+	}
+
+	return content, nil
+}
+
+// urlSnapshot 读取已发布的输出，如 defaultBaseURL，文件列表来自其中的 index.json
+type urlSnapshot string
+
+func (u urlSnapshot) List() ([]string, error) {
+	content, err := u.Read(manifest.FileName)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &manifest.Index{}
+	if err := json.Unmarshal(content, index); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", manifest.FileName, err)
+	}
+
+	files := make([]string, 0, len(index.Files))
+	for _, entry := range index.Files {
+		files = append(files, entry.File)
+	}
+
+	return files, nil
+}
+
+func (u urlSnapshot) Read(file string) ([]byte, error) {
+	location := strings.TrimSuffix(string(u), "/") + "/" + neturl.PathEscape(file)
+
+	resp, err := http.Get(location)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("get %s: %w", location, fs.ErrNotExist)
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("get %s: response %s", location, resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// openSnapshot 根据 location 是否为 http(s) 地址返回 urlSnapshot 或 dirSnapshot
+func openSnapshot(location string) snapshot {
+	if dlc.IsURL(location) {
+		return urlSnapshot(location)
+	}
+
+	return dirSnapshot(location)
+}
+
+func runDiff(args []string) error {
+	flags := newFlagSet("diff")
+	repo := flags.String("repo", ".", "git repository used with -ref")
+	ref := flags.String("ref", "", "compare <new> against the files of this git ref instead of <old>")
+	format := flags.String("format", "text", "report format: text, markdown or json")
+	limit := flags.Int("limit", 20, "max entries listed per provider in text and markdown, 0 for unlimited")
+
+	_ = flags.Parse(args)
+
+	var oldSnapshot, newSnapshot snapshot
+
+	switch {
+	case *ref != "" && flags.NArg() == 1:
+		oldSnapshot = &gitSnapshot{Repo: *repo, Ref: *ref}
+		newSnapshot = openSnapshot(flags.Arg(0))
+	case *ref == "" && flags.NArg() == 2:
+		oldSnapshot = openSnapshot(flags.Arg(0))
+		newSnapshot = openSnapshot(flags.Arg(1))
+	default:
+		flags.Usage()
+
+		return errUsage
+	}
+
+	oldProviders, err := loadSnapshot(oldSnapshot)
+	if err != nil {
+		return fmt.Errorf("load old: %w", err)
+	}
+
+	newProviders, err := loadSnapshot(newSnapshot)
+	if err != nil {
+		return fmt.Errorf("load new: %w", err)
+	}
+
+	diffs := diffProviders(oldProviders, newProviders)
+
+	w := bufio.NewWriter(os.Stdout)
+
+	switch *format {
+	case "text":
+		writeDiffText(w, diffs, *limit)
+	case "markdown":
+		writeDiffMarkdown(w, diffs, *limit)
+	case "json":
+		content, err := json.MarshalIndent(diffs, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		_, _ = w.Write(append(content, '\n'))
+	default:
+		return fmt.Errorf("unknown format %s", *format)
+	}
+
+	return w.Flush()
+}
+
+// loadSnapshot 读取全部 clash 格式的规则集，有 index.json 时从中读取 behavior，否则根据内容推断
+func loadSnapshot(s snapshot) (map[string]*provider, error) {
+	files, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	behaviors := map[string]string{}
+
+	if content, err := s.Read(manifest.FileName); err == nil {
+		index := &manifest.Index{}
+		if err := json.Unmarshal(content, index); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", manifest.FileName, err)
+		}
+
+		behaviors = index.Behaviors()
+	}
+
+	providers := map[string]*provider{}
+
+	for _, file := range files {
+		if !strings.HasSuffix(file, ".yaml") || file == clashConfigName {
+			continue
+		}
+
+		content, err := s.Read(file)
+		if err != nil {
+			return nil, err
+		}
+
+		rules, err := output.ReadClash(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", file, err)
+		}
+
+		name := strings.TrimSuffix(file, ".yaml")
+
+		behavior, ok := behaviors[name]
+		if !ok {
+			behavior = guessBehavior(rules)
+		}
+
+		providers[name] = &provider{
+			Name:     name,
+			Behavior: behavior,
+			Rules:    rules,
+		}
+	}
+
+	return providers, nil
+}
+
+// guessBehavior 根据第一条规则推断 behavior
+func guessBehavior(rules []string) string {
+	if len(rules) == 0 {
+		return output.Domain
+	}
+
+	if _, _, err := net.ParseCIDR(rules[0]); err == nil {
+		return output.IPCIDR
+	}
+
+	if typ, _, ok := strings.Cut(rules[0], ","); ok && strings.ToUpper(typ) == typ {
+		return output.Classical
+	}
+
+	return output.Domain
+}
+
+// diffProviders 比较两次生成的规则集，只返回有差异的规则集
+func diffProviders(oldProviders, newProviders map[string]*provider) []*providerDiff {
+	names := map[string]bool{}
+	for name := range oldProviders {
+		names[name] = true
+	}
+	for name := range newProviders {
+		names[name] = true
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+
+	sort.Strings(sorted)
+
+	var diffs []*providerDiff
+
+	for _, name := range sorted {
+		oldProvider, newProvider := oldProviders[name], newProviders[name]
+
+		switch {
+		case oldProvider == nil:
+			diffs = append(diffs, &providerDiff{Provider: name, Status: "added", Added: newProvider.Rules})
+		case newProvider == nil:
+			diffs = append(diffs, &providerDiff{Provider: name, Status: "deleted", Removed: oldProvider.Rules})
+		default:
+			if d := diffProvider(oldProvider, newProvider); d != nil {
+				diffs = append(diffs, d)
+			}
+		}
+	}
+
+	return diffs
+}
+
+// diffProvider 比较同一规则集的两个版本，被删除但仍被新规则覆盖的条目记为 subsumed
+func diffProvider(oldProvider, newProvider *provider) *providerDiff {
+	oldRules := map[string]bool{}
+	for _, rule := range oldProvider.Rules {
+		oldRules[rule] = true
+	}
+
+	newRules := map[string]bool{}
+	for _, rule := range newProvider.Rules {
+		newRules[rule] = true
+	}
+
+	d := &providerDiff{Provider: newProvider.Name, Status: "changed"}
+
+	for _, rule := range newProvider.Rules {
+		if !oldRules[rule] {
+			d.Added = append(d.Added, rule)
+		}
+	}
+
+	set := match.New(newProvider.Behavior, newProvider.Rules)
+
+	for _, rule := range oldProvider.Rules {
+		if newRules[rule] {
+			continue
+		}
+
+		if by, ok := coveredBy(set, newProvider.Behavior, rule); ok {
+			d.Subsumed = append(d.Subsumed, subsumedEntry{Entry: rule, By: by})
+		} else {
+			d.Removed = append(d.Removed, rule)
+		}
+	}
+
+	if len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Subsumed) == 0 {
+		return nil
+	}
+
+	return d
+}
+
+// coveredBy 判断规则 rule 匹配的全部范围是否仍被 set 覆盖，返回覆盖它的规则
+func coveredBy(set *match.Set, behavior, rule string) (string, bool) {
+	typ, payload := "", rule
+	if behavior == output.Classical {
+		typ, payload, _ = strings.Cut(rule, ",")
+		payload, _, _ = strings.Cut(payload, ",")
+	}
+
+	switch {
+	case behavior == output.IPCIDR || typ == "IP-CIDR" || typ == "IP-CIDR6":
+		_, cidr, err := net.ParseCIDR(payload)
+		if err != nil {
+			return "", false
+		}
+
+		return set.MatchCIDR(cidr)
+	case behavior == output.Domain || typ == "DOMAIN" || typ == "DOMAIN-SUFFIX":
+		suffix := typ == "DOMAIN-SUFFIX" || strings.HasPrefix(payload, "+.")
+		domain := strings.TrimPrefix(payload, "+.")
+
+		by, ok := set.Match(domain)
+		if !ok {
+			return "", false
+		}
+
+		// 后缀规则只能被后缀规则完整覆盖
+		if suffix && !strings.HasPrefix(by, "+.") && !strings.HasPrefix(by, "DOMAIN-SUFFIX,") {
+			return "", false
+		}
+
+		return by, true
+	default:
+		return "", false
+	}
+}
+
+func writeDiffText(w io.Writer, diffs []*providerDiff, limit int) {
+	if len(diffs) == 0 {
+		_, _ = fmt.Fprintln(w, "No changes")
+
+		return
+	}
+
+	for _, d := range diffs {
+		_, _ = fmt.Fprintf(w, "%s (%s): +%d -%d ~%d\n", d.Provider, d.Status, len(d.Added), len(d.Removed), len(d.Subsumed))
+
+		for _, rule := range truncate(d.Added, limit) {
+			_, _ = fmt.Fprintf(w, "  + %s\n", rule)
+		}
+
+		for _, rule := range truncate(d.Removed, limit) {
+			_, _ = fmt.Fprintf(w, "  - %s\n", rule)
+		}
+
+		for i, s := range d.Subsumed {
+			if limit > 0 && i >= limit {
+				break
+			}
+
+			_, _ = fmt.Fprintf(w, "  ~ %s (covered by %s)\n", s.Entry, s.By)
+		}
+	}
+}
+
+func writeDiffMarkdown(w io.Writer, diffs []*providerDiff, limit int) {
+	if len(diffs) == 0 {
+		_, _ = fmt.Fprintln(w, "No changes.")
+
+		return
+	}
+
+	_, _ = fmt.Fprintln(w, "| Provider | Status | Added | Removed | Subsumed |")
+	_, _ = fmt.Fprintln(w, "| -------- | ------ | ----: | ------: | -------: |")
+
+	for _, d := range diffs {
+		_, _ = fmt.Fprintf(w, "| `%s` | %s | %d | %d | %d |\n", d.Provider, d.Status, len(d.Added), len(d.Removed), len(d.Subsumed))
+	}
+
+	for _, d := range diffs {
+		if d.Status != "changed" {
+			continue
+		}
+
+		_, _ = fmt.Fprintf(w, "\n<details><summary><code>%s</code></summary>\n\n```diff\n", d.Provider)
+
+		for _, rule := range truncate(d.Added, limit) {
+			_, _ = fmt.Fprintf(w, "+ %s\n", rule)
+		}
+
+		for _, rule := range truncate(d.Removed, limit) {
+			_, _ = fmt.Fprintf(w, "- %s\n", rule)
+		}
+
+		for i, s := range d.Subsumed {
+			if limit > 0 && i >= limit {
+				break
+			}
+
+			_, _ = fmt.Fprintf(w, "  %s (covered by %s)\n", s.Entry, s.By)
+		}
+
+		_, _ = fmt.Fprintln(w, "```\n\n</details>")
+	}
+}
+
+func truncate(list []string, limit int) []string {
+	if limit > 0 && len(list) > limit {
+		return list[:limit]
+	}
+
+	return list
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kr328/domains2providers/match"
+	"github.com/kr328/domains2providers/output"
+)
+
+func TestCoveredBy(t *testing.T) {
+	tests := []struct {
+		name     string
+		behavior string
+		rules    []string
+		rule     string
+		by       string
+		ok       bool
+	}{
+		{"suffix covers full", output.Domain, []string{"+.qq.com"}, "www.qq.com", "+.qq.com", true},
+		{"suffix covers suffix", output.Domain, []string{"+.qq.com"}, "+.im.qq.com", "+.qq.com", true},
+		{"full does not cover suffix", output.Domain, []string{"qq.com"}, "+.qq.com", "", false},
+		{"classical suffix", output.Classical, []string{"DOMAIN-SUFFIX,qq.com"}, "DOMAIN,www.qq.com", "DOMAIN-SUFFIX,qq.com", true},
+		{"larger network", output.IPCIDR, []string{"10.0.0.0/8"}, "10.1.0.0/16", "10.0.0.0/8", true},
+		{"smaller network", output.IPCIDR, []string{"10.1.0.0/16"}, "10.0.0.0/8", "", false},
+		// 第一个包含起始地址的网段更小，但后面重叠的网段完整覆盖
+		{"overlapping networks", output.IPCIDR, []string{"10.0.0.0/24", "10.0.0.0/8"}, "10.0.0.0/16", "10.0.0.0/8", true},
+		{"ipv6", output.IPCIDR, []string{"2001:db8::/48", "2001:db8::/32"}, "2001:db8::/40", "2001:db8::/32", true},
+		{"ipv4 does not cover ipv6", output.IPCIDR, []string{"0.0.0.0/0"}, "::/0", "", false},
+		{"classical networks", output.Classical, []string{"IP-CIDR,10.0.0.0/24", "IP-CIDR,10.0.0.0/8,no-resolve"}, "IP-CIDR,10.0.0.0/16", "IP-CIDR,10.0.0.0/8,no-resolve", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			by, ok := coveredBy(match.New(test.behavior, test.rules), test.behavior, test.rule)
+			if by != test.by || ok != test.ok {
+				t.Errorf("got %q, %v, want %q, %v", by, ok, test.by, test.ok)
+			}
+		})
+	}
+}
+
+func TestGitSnapshotList(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	repo := t.TempDir()
+
+	git := func(args ...string) {
+		t.Helper()
+
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	git("init", "-q")
+
+	files := []string{"geolocation-!cn@cn.yaml", "name with space.yaml", "quoted\"name.yaml"}
+	for _, file := range files {
+		if err := os.WriteFile(filepath.Join(repo, file), []byte("payload:\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("add", "-A")
+	git("commit", "-q", "-m", "init")
+
+	listed, err := (&gitSnapshot{Repo: repo, Ref: "HEAD"}).List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(listed, files) {
+		t.Errorf("got %q, want %q", listed, files)
+	}
+}
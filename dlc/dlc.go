@@ -0,0 +1,370 @@
+package dlc
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kr328/domains2providers/manifest"
+)
+
+var (
+	ErrUnsupportedSource = errors.New("unsupported source, expected a directory, .zip, .tar, .tar.gz, .tgz or .dat")
+	ErrNoData            = errors.New("no data directory found")
+	ErrTooLarge          = errors.New("download too large")
+)
+
+// maxDownloadSize 为下载的数据大小上限，domain-list-community 的归档与 dat 都只有几 MB
+var maxDownloadSize int64 = 256 << 20
+
+// Source 为 domain-list-community 数据的来源，可以是仓库目录、仓库的 zip 或 tar 归档、
+// 或发布的 dlc.dat/geosite.dat，均可为本地路径或 URL。归档与 dat 会被解压为临时的数据目录
+type Source struct {
+	Dir       string // 数据目录，即仓库中的 data
+	Commit    string // 数据所属的提交，无法确定时为空
+	Extracted bool   // 数据目录是否为临时解压的，解压的数据不会再变化
+
+	temp string
+}
+
+// IsURL 判断 location 是否为 http(s) URL，Open 会从网络下载这样的数据
+func IsURL(location string) bool {
+	u, err := neturl.Parse(location)
+
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
+
+// Open 打开 location 处的数据，使用完毕后需调用 Close 删除临时文件
+func Open(location string) (*Source, error) {
+	if IsURL(location) {
+		u, err := neturl.Parse(location)
+		if err != nil {
+			return nil, err
+		}
+
+		return openURL(u)
+	}
+
+	info, err := os.Stat(location)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		commit, _ := manifest.GitCommit(location)
+
+		return &Source{Dir: filepath.Join(location, "data"), Commit: commit}, nil
+	}
+
+	return openFile(location, location)
+}
+
+// Close 删除解压的临时数据目录
+func (s *Source) Close() error {
+	if s.temp == "" {
+		return nil
+	}
+
+	return os.RemoveAll(s.temp)
+}
+
+// openURL 下载 u 到临时文件后按文件打开
+func openURL(u *neturl.URL) (*Source, error) {
+	start := time.Now()
+
+	resp, err := http.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("download %s: response %s", u, resp.Status)
+	}
+
+	if resp.ContentLength > maxDownloadSize {
+		return nil, fmt.Errorf("download %s: %w: %d bytes, max %d", u, ErrTooLarge, resp.ContentLength, maxDownloadSize)
+	}
+
+	file, err := os.CreateTemp("", "dlc-*-"+path.Base(u.Path))
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+
+	size, err := io.Copy(file, io.LimitReader(resp.Body, maxDownloadSize+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && size > maxDownloadSize {
+		err = fmt.Errorf("%w: more than %d bytes", ErrTooLarge, maxDownloadSize)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", u, err)
+	}
+
+	slog.Info("downloaded", "url", u.String(), "bytes", size, "duration", time.Since(start))
+
+	return openFile(file.Name(), u.Path)
+}
+
+// openFile 将 file 解压到临时数据目录。归档按文件头识别，如 codeload.github.com 的 URL 没有扩展名；
+// 无法识别时按 name 的扩展名处理，dat 没有文件头，只按扩展名识别
+func openFile(file, name string) (*Source, error) {
+	extract, err := sniff(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if extract == nil {
+		switch name = strings.ToLower(name); {
+		case strings.HasSuffix(name, ".zip"):
+			extract = extractZip
+		case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"), strings.HasSuffix(name, ".tar"):
+			extract = extractTar
+		case strings.HasSuffix(name, ".dat"):
+			extract = extractGeoSite
+		default:
+			return nil, ErrUnsupportedSource
+		}
+	}
+
+	temp, err := os.MkdirTemp("", "dlc-*")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Source{Dir: filepath.Join(temp, "data"), Extracted: true, temp: temp}
+
+	if err := os.Mkdir(s.Dir, 0755); err != nil {
+		_ = s.Close()
+
+		return nil, err
+	}
+
+	s.Commit, err = extract(file, s.Dir)
+	if err != nil {
+		_ = s.Close()
+
+		return nil, fmt.Errorf("extract %s: %w", path.Base(name), err)
+	}
+
+	return s, nil
+}
+
+// sniff 按文件头识别 zip、gzip 与 tar 归档，返回其解压函数，无法识别时返回 nil
+func sniff(file string) (func(file, dir string) (string, error), error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// tar 的 ustar 标识位于偏移 257 处
+	header := make([]byte, 262)
+
+	n, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return extractZip, nil
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return extractTar, nil
+	case len(header) == 262 && string(header[257:]) == "ustar":
+		return extractTar, nil
+	default:
+		return nil, nil
+	}
+}
+
+// dataPrefix 返回归档中层级最浅的 data 目录，如 domain-list-community-master/data
+func dataPrefix(names []string) (string, error) {
+	prefix := ""
+
+	for _, name := range names {
+		dir := path.Dir(strings.TrimPrefix(name, "./"))
+		if path.Base(dir) != "data" {
+			continue
+		}
+
+		if prefix == "" || strings.Count(dir, "/") < strings.Count(prefix, "/") {
+			prefix = dir
+		}
+	}
+
+	if prefix == "" {
+		return "", ErrNoData
+	}
+
+	return prefix, nil
+}
+
+// writeDataFile 将归档中 data 目录下的文件 name 写入 dir，其余文件被忽略
+func writeDataFile(dir, prefix, name string, r io.Reader) error {
+	name = strings.TrimPrefix(name, "./")
+	if path.Dir(name) != prefix || strings.HasPrefix(path.Base(name), ".") {
+		return nil
+	}
+
+	file, err := os.Create(filepath.Join(dir, path.Base(name)))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(file, r)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// extractZip 解压 zip 归档中的 data 目录，GitHub 生成的归档的注释为提交
+func extractZip(file, dir string) (string, error) {
+	archive, err := zip.OpenReader(file)
+	if err != nil {
+		return "", err
+	}
+	defer archive.Close()
+
+	names := make([]string, 0, len(archive.File))
+	for _, f := range archive.File {
+		if f.Mode().IsRegular() {
+			names = append(names, f.Name)
+		}
+	}
+
+	prefix, err := dataPrefix(names)
+	if err != nil {
+		return "", err
+	}
+
+	for _, f := range archive.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+
+		r, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+
+		err = writeDataFile(dir, prefix, f.Name, r)
+		_ = r.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return commitOf(archive.Comment), nil
+}
+
+// extractTar 解压 tar 或 tar.gz 归档中的 data 目录，GitHub 生成的归档的 pax 全局头注释为提交
+func extractTar(file, dir string) (string, error) {
+	open := func() (*tar.Reader, io.Closer, error) {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			// 不是 gzip 压缩的 tar
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				_ = f.Close()
+
+				return nil, nil, err
+			}
+
+			return tar.NewReader(f), f, nil
+		}
+
+		return tar.NewReader(gz), f, nil
+	}
+
+	// 第一遍找出 data 目录与提交，第二遍解压
+	r, closer, err := open()
+	if err != nil {
+		return "", err
+	}
+
+	var names []string
+	commit := ""
+
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			_ = closer.Close()
+
+			return "", err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeXGlobalHeader:
+			commit = commitOf(header.PAXRecords["comment"])
+		case tar.TypeReg:
+			names = append(names, header.Name)
+		}
+	}
+
+	_ = closer.Close()
+
+	prefix, err := dataPrefix(names)
+	if err != nil {
+		return "", err
+	}
+
+	r, closer, err = open()
+	if err != nil {
+		return "", err
+	}
+	defer closer.Close()
+
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+
+		if header.Typeflag == tar.TypeReg {
+			if err := writeDataFile(dir, prefix, header.Name, r); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return commit, nil
+}
+
+// commitOf 在 comment 为完整的提交哈希时返回它
+func commitOf(comment string) string {
+	comment = strings.TrimSpace(comment)
+
+	if _, err := hex.DecodeString(comment); err != nil || len(comment) != 40 {
+		return ""
+	}
+
+	return comment
+}
@@ -0,0 +1,212 @@
+package dlc
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testCommit = "0123456789abcdef0123456789abcdef01234567"
+
+// testArchiveFiles 为 GitHub 归档的内容，tests 下同样有 data 目录，只有层级最浅的被解压
+var testArchiveFiles = []struct {
+	name    string
+	content string
+}{
+	{"domain-list-community-master/README.md", "readme\n"},
+	{"domain-list-community-master/data/google", "google.com\n"},
+	{"domain-list-community-master/data/cn", "cn\n"},
+	{"domain-list-community-master/data/.hidden", "ignored\n"},
+	{"domain-list-community-master/tests/data/ignored", "ignored.com\n"},
+}
+
+func testZip(t *testing.T) []byte {
+	buf := &bytes.Buffer{}
+
+	w := zip.NewWriter(buf)
+	for _, f := range testArchiveFiles {
+		fw, err := w.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _ = fw.Write([]byte(f.content))
+	}
+
+	if err := w.SetComment(testCommit); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func testTar(t *testing.T) []byte {
+	buf := &bytes.Buffer{}
+
+	w := tar.NewWriter(buf)
+
+	// GitHub 的归档在 pax 全局头的注释中记录提交
+	if err := w.WriteHeader(&tar.Header{
+		Typeflag:   tar.TypeXGlobalHeader,
+		Name:       "pax_global_header",
+		PAXRecords: map[string]string{"comment": testCommit},
+		Format:     tar.FormatPAX,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range testArchiveFiles {
+		if err := w.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: f.name, Mode: 0644, Size: int64(len(f.content))}); err != nil {
+			t.Fatal(err)
+		}
+
+		_, _ = w.Write([]byte(f.content))
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func testTgz(t *testing.T) []byte {
+	buf := &bytes.Buffer{}
+
+	w := gzip.NewWriter(buf)
+	_, _ = w.Write(testTar(t))
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestDataPrefix(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  string
+		err   error
+	}{
+		{[]string{"repo-master/data/google", "repo-master/README.md"}, "repo-master/data", nil},
+		{[]string{"./repo/tests/data/a", "./repo/data/b"}, "repo/data", nil},
+		{[]string{"data/google"}, "data", nil},
+		{[]string{"repo/metadata/google", "repo/README.md"}, "", ErrNoData},
+		{nil, "", ErrNoData},
+	}
+
+	for _, test := range tests {
+		prefix, err := dataPrefix(test.names)
+		if prefix != test.want || !errors.Is(err, test.err) {
+			t.Errorf("dataPrefix(%v) = %q, %v, want %q, %v", test.names, prefix, err, test.want, test.err)
+		}
+	}
+}
+
+func TestOpenArchive(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{"master.zip", testZip(t)},
+		{"master.tar.gz", testTgz(t)},
+		{"master.tar", testTar(t)},
+		// 没有扩展名时按文件头识别，如 codeload.github.com 的 URL
+		{"master", testZip(t)},
+		{"master", testTgz(t)},
+		{"master", testTar(t)},
+	}
+
+	for _, test := range tests {
+		file := filepath.Join(t.TempDir(), "archive")
+		if err := os.WriteFile(file, test.content, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := openFile(file, test.name)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+
+			continue
+		}
+
+		entries, err := os.ReadDir(s.Dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+
+		if len(names) != 2 || names[0] != "cn" || names[1] != "google" {
+			t.Errorf("%s: data files = %v, want cn and google", test.name, names)
+		}
+
+		if s.Commit != testCommit {
+			t.Errorf("%s: commit = %q, want %q", test.name, s.Commit, testCommit)
+		}
+
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestOpenUnsupported(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "archive")
+	if err := os.WriteFile(file, []byte("<html></html>\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := openFile(file, "master"); !errors.Is(err, ErrUnsupportedSource) {
+		t.Errorf("err = %v, want ErrUnsupportedSource", err)
+	}
+}
+
+func TestOpenURL(t *testing.T) {
+	archive := testZip(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked" {
+			// 不设置 Content-Length，只能在下载时检查大小
+			w.(http.Flusher).Flush()
+		}
+
+		_, _ = w.Write(archive)
+	}))
+	defer server.Close()
+
+	s, err := Open(server.URL + "/v2fly/domain-list-community/zip/refs/heads/master")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Commit != testCommit {
+		t.Errorf("commit = %q, want %q", s.Commit, testCommit)
+	}
+
+	_ = s.Close()
+
+	defer func(saved int64) { maxDownloadSize = saved }(maxDownloadSize)
+	maxDownloadSize = int64(len(archive) - 1)
+
+	for _, path := range []string{"/master.zip", "/chunked"} {
+		if _, err := Open(server.URL + path); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("%s: err = %v, want ErrTooLarge", path, err)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package dlc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrMalformedGeoSite = errors.New("malformed geosite data")
+
+// geosite.dat 中 Domain.Type 的取值
+const (
+	typePlain  = 0 // 关键字
+	typeRegex  = 1
+	typeDomain = 2 // 域名及其子域名
+	typeFull   = 3
+)
+
+// protobuf 的 wire type
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// geoSite 为 geosite.dat 中的一个规则集
+type geoSite struct {
+	name    string
+	domains []*geoDomain
+}
+
+// geoDomain 为 geosite.dat 中的一条规则
+type geoDomain struct {
+	kind       uint64
+	value      string
+	attributes []string
+}
+
+// extractGeoSite 将 dlc.dat/geosite.dat 中的规则集按数据文件的格式写入 dir。
+// dat 中的规则集已展开 include，提交无法确定
+func extractGeoSite(file, dir string) (string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	sites, err := decodeGeoSiteList(content)
+	if err != nil {
+		return "", err
+	}
+
+	for _, site := range sites {
+		if err := writeGeoSite(dir, site); err != nil {
+			return "", err
+		}
+	}
+
+	return "", nil
+}
+
+// writeGeoSite 将 site 写为数据文件，文件名为小写的规则集名称
+func writeGeoSite(dir string, site *geoSite) error {
+	name := strings.ToLower(site.name)
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("%w: invalid name %q", ErrMalformedGeoSite, site.name)
+	}
+
+	file, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+
+	for _, domain := range site.domains {
+		switch domain.kind {
+		case typePlain:
+			_, _ = w.WriteString("keyword:")
+		case typeRegex:
+			_, _ = w.WriteString("regexp:")
+		case typeFull:
+			_, _ = w.WriteString("full:")
+		case typeDomain:
+		default:
+			continue
+		}
+
+		_, _ = w.WriteString(domain.value)
+
+		for _, attribute := range domain.attributes {
+			_, _ = w.WriteString(" @" + attribute)
+		}
+
+		_ = w.WriteByte('\n')
+	}
+
+	err = w.Flush()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// decodeGeoSiteList 解码 GeoSiteList 消息
+func decodeGeoSiteList(data []byte) ([]*geoSite, error) {
+	var sites []*geoSite
+
+	err := decodeMessage(data, func(field int, value []byte, _ uint64) error {
+		if field != 1 {
+			return nil
+		}
+
+		site, err := decodeGeoSite(value)
+		if err != nil {
+			return err
+		}
+
+		sites = append(sites, site)
+
+		return nil
+	})
+
+	return sites, err
+}
+
+// decodeGeoSite 解码 GeoSite 消息：country_code = 1，domain = 2
+func decodeGeoSite(data []byte) (*geoSite, error) {
+	site := &geoSite{}
+
+	err := decodeMessage(data, func(field int, value []byte, _ uint64) error {
+		switch field {
+		case 1:
+			site.name = string(value)
+		case 2:
+			domain, err := decodeDomain(value)
+			if err != nil {
+				return err
+			}
+
+			site.domains = append(site.domains, domain)
+		}
+
+		return nil
+	})
+
+	return site, err
+}
+
+// decodeDomain 解码 Domain 消息：type = 1，value = 2，attribute = 3
+func decodeDomain(data []byte) (*geoDomain, error) {
+	domain := &geoDomain{}
+
+	err := decodeMessage(data, func(field int, value []byte, number uint64) error {
+		switch field {
+		case 1:
+			domain.kind = number
+		case 2:
+			domain.value = string(value)
+		case 3:
+			key, err := decodeAttributeKey(value)
+			if err != nil {
+				return err
+			}
+
+			domain.attributes = append(domain.attributes, key)
+		}
+
+		return nil
+	})
+
+	return domain, err
+}
+
+// decodeAttributeKey 解码 Domain.Attribute 消息中的 key = 1，数据文件中的属性只有名称
+func decodeAttributeKey(data []byte) (string, error) {
+	key := ""
+
+	err := decodeMessage(data, func(field int, value []byte, _ uint64) error {
+		if field == 1 {
+			key = string(value)
+		}
+
+		return nil
+	})
+
+	return key, err
+}
+
+// decodeMessage 依次解码 protobuf 消息中的字段，length-delimited 字段的内容通过 value 传入，varint 字段通过 number 传入
+func decodeMessage(data []byte, field func(field int, value []byte, number uint64) error) error {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return ErrMalformedGeoSite
+		}
+
+		data = data[n:]
+
+		var value []byte
+		var number uint64
+
+		switch tag & 7 {
+		case wireVarint:
+			number, n = binary.Uvarint(data)
+			if n <= 0 {
+				return ErrMalformedGeoSite
+			}
+
+			data = data[n:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return ErrMalformedGeoSite
+			}
+
+			value = data[n : n+int(length)]
+			data = data[n+int(length):]
+		case wireFixed64:
+			if len(data) < 8 {
+				return ErrMalformedGeoSite
+			}
+
+			data = data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return ErrMalformedGeoSite
+			}
+
+			data = data[4:]
+		default:
+			return ErrMalformedGeoSite
+		}
+
+		if err := field(int(tag>>3), value, number); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package dlc
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// encodeBytes 编码 length-delimited 字段
+func encodeBytes(field int, value []byte) []byte {
+	data := binary.AppendUvarint(nil, uint64(field<<3|wireBytes))
+	data = binary.AppendUvarint(data, uint64(len(value)))
+
+	return append(data, value...)
+}
+
+// encodeVarint 编码 varint 字段
+func encodeVarint(field int, value uint64) []byte {
+	data := binary.AppendUvarint(nil, uint64(field<<3|wireVarint))
+
+	return binary.AppendUvarint(data, value)
+}
+
+// encodeDomain 编码 Domain 消息，attributes 为布尔属性
+func encodeDomain(kind uint64, value string, attributes ...string) []byte {
+	data := append(encodeVarint(1, kind), encodeBytes(2, []byte(value))...)
+
+	for _, attribute := range attributes {
+		attr := append(encodeBytes(1, []byte(attribute)), encodeVarint(2, 1)...)
+		data = append(data, encodeBytes(3, attr)...)
+	}
+
+	return data
+}
+
+// testGeoSiteList 为包含 GOOGLE 与 CN 两个规则集的 GeoSiteList
+func testGeoSiteList() []byte {
+	google := encodeBytes(1, []byte("GOOGLE"))
+	google = append(google, encodeBytes(2, encodeDomain(typeDomain, "google.com"))...)
+	google = append(google, encodeBytes(2, encodeDomain(typeFull, "www.google.cn", "cn"))...)
+	google = append(google, encodeBytes(2, encodeDomain(typePlain, "google", "ads", "cn"))...)
+	google = append(google, encodeBytes(2, encodeDomain(typeRegex, `^ad\.google\.[a-z]+$`))...)
+
+	cn := append(encodeBytes(1, []byte("CN")), encodeBytes(2, encodeDomain(typeDomain, "cn"))...)
+
+	return append(encodeBytes(1, google), encodeBytes(1, cn)...)
+}
+
+func TestDecodeGeoSiteList(t *testing.T) {
+	list := testGeoSiteList()
+
+	// 未知的 fixed32 与 fixed64 字段被跳过
+	unknown := append(binary.AppendUvarint(nil, 9<<3|wireFixed32), 0, 0, 0, 0)
+	unknown = append(unknown, binary.AppendUvarint(nil, 10<<3|wireFixed64)...)
+	unknown = append(unknown, make([]byte, 8)...)
+
+	want := []*geoSite{
+		{
+			name: "GOOGLE",
+			domains: []*geoDomain{
+				{kind: typeDomain, value: "google.com"},
+				{kind: typeFull, value: "www.google.cn", attributes: []string{"cn"}},
+				{kind: typePlain, value: "google", attributes: []string{"ads", "cn"}},
+				{kind: typeRegex, value: `^ad\.google\.[a-z]+$`},
+			},
+		},
+		{name: "CN", domains: []*geoDomain{{kind: typeDomain, value: "cn"}}},
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want []*geoSite
+		err  error
+	}{
+		{name: "list", data: list, want: want},
+		{name: "unknown fields", data: append(append([]byte{}, unknown...), list...), want: want},
+		{name: "empty", data: nil},
+		{name: "truncated", data: list[:len(list)-1], err: ErrMalformedGeoSite},
+		{name: "truncated varint", data: []byte{0x0a, 0x80}, err: ErrMalformedGeoSite},
+		{name: "over-long length", data: append(binary.AppendUvarint([]byte{0x0a}, uint64(len(list)+1)), list...), err: ErrMalformedGeoSite},
+		{name: "huge length", data: append([]byte{0x0a}, binary.AppendUvarint(nil, 1<<63)...), err: ErrMalformedGeoSite},
+		{name: "truncated fixed64", data: append(binary.AppendUvarint(nil, 1<<3|wireFixed64), 0, 0), err: ErrMalformedGeoSite},
+		{name: "unknown wire type", data: []byte{1<<3 | 3}, err: ErrMalformedGeoSite},
+	}
+
+	for _, test := range tests {
+		sites, err := decodeGeoSiteList(test.data)
+
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: err = %v, want %v", test.name, err, test.err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+
+			continue
+		}
+
+		if !reflect.DeepEqual(sites, test.want) {
+			t.Errorf("%s: sites differ", test.name)
+		}
+	}
+}
+
+func TestExtractGeoSite(t *testing.T) {
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "geosite.dat")
+	if err := os.WriteFile(file, testGeoSiteList(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data := filepath.Join(dir, "data")
+	if err := os.Mkdir(data, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := extractGeoSite(file, data); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"google": "google.com\nfull:www.google.cn @cn\nkeyword:google @ads @cn\nregexp:^ad\\.google\\.[a-z]+$\n",
+		"cn":     "cn\n",
+	}
+
+	for name, want := range tests {
+		content, err := os.ReadFile(filepath.Join(data, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(content) != want {
+			t.Errorf("%s = %q, want %q", name, content, want)
+		}
+	}
+}
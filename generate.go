@@ -0,0 +1,823 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kr328/domains2providers/dlc"
+	"github.com/kr328/domains2providers/manifest"
+	"github.com/kr328/domains2providers/output"
+	"github.com/kr328/domains2providers/raw"
+	"github.com/kr328/domains2providers/rule"
+	"github.com/kr328/domains2providers/trie"
+)
+
+const (
+	stageGenerate = "generate"
+	stageRaw      = "raw"
+	stageConfig   = "config"
+)
+
+// provider 描述一个待输出的规则集
+type provider struct {
+	Name     string
+	Tag      string
+	Behavior string
+	Rules    []string
+	Sources  []string // 原始规则的来源地址
+	Commit   string   // domain-list-community 提交
+	Stats    manifest.Stats
+	Inputs   string            // 输入的 sha256，与上次相同时沿用上次的输出，为空时总是重新输出
+	ETags    map[string]string // 来源URL到响应的 ETag
+}
+
+// OutputName 返回输出文件名（不含扩展名），如 google@cn
+func (p *provider) OutputName() string {
+	if p.Tag == "" {
+		return p.Name
+	}
+
+	return fmt.Sprintf("%s@%s", p.Name, p.Tag)
+}
+
+// generator 负责将规则集写入输出目录并更新索引
+type generator struct {
+	dir     string
+	stage   string
+	only    []string // 需要输出的规则集，为空时输出全部
+	formats []string // 需要输出的格式，为空时按 dnsOutputs 决定
+	entries []*manifest.Entry
+	removed []string       // 本次删除的文件
+	pending []*pendingFile // 暂存的写入与删除，finish 时没有规则集触发 guardAbort 才执行
+	report  report
+
+	current   map[string]*manifest.Entry // 输出目录中现有的索引
+	force     bool                       // 是否忽略 current 总是重新输出
+	updated   map[string]bool            // 内容有变化而重新写入的规则集
+	unchanged int                        // 内容未变化而未写入的文件数
+	fail      bool                       // 有错误时是否以非零状态退出
+
+	header    bool   // 是否在输出文件开头写入注释头
+	timestamp bool   // 是否在注释头与索引中记录生成时间
+	lock      string // 原始规则来源的锁文件，指定时只使用锁定的内容，仅 raw 使用
+
+	previousOutput snapshot                   // 上次的输出，用于回归保护
+	previous       map[string]*manifest.Entry // 上次输出的索引
+	aborted        bool                       // 是否有规则集触发了 guardAbort
+
+	watch         bool          // 生成后是否继续监视数据目录，仅 generate 使用
+	watchInterval time.Duration // 轮询数据目录的间隔
+
+	domains map[string][]string // clash 文件名到本次输出的 domain 规则，设置了 publishDomains 时记录
+}
+
+// pendingFile 为暂存的输出文件，content 为 nil 时表示删除该文件
+type pendingFile struct {
+	file    string
+	content []byte
+	entry   *manifest.Entry // 写入失败时从索引中移除
+}
+
+// newGenerator 解析 generate 与 raw 共用的参数
+func newGenerator(stage string, args []string, positional int) (*generator, []string, error) {
+	flags := newFlagSet(stage)
+	only := flags.String("only", "", "comma separated rulesets to emit: name, name@tag, @tag or name@*")
+	formatList := flags.String("formats", "", "comma separated output formats, defaults to clash and the dns formats in dnsOutputs")
+	fail := flags.Bool("fail-on-error", true, "exit with non-zero status if any ruleset failed to load, resolve or write")
+	force := flags.Bool("force", false, "rewrite every output even if its inputs and content are unchanged")
+	previous := flags.String("previous", "", "previous output, a directory or url, compared by the regression guards (default the output path)")
+	header := flags.Bool("header", false, "write a comment header with the generator version, data commit and sources to formats supporting comments")
+	timestamp := flags.Bool("timestamp", false, "record the generation time in the comment header and index.json, output is no longer reproducible")
+	reproducible := flags.Bool("reproducible", false, "produce byte-identical output for identical inputs: never fetch from the network, raw sources must be pinned with -lock")
+
+	var watch *bool
+	var lock *string
+	if stage == stageRaw {
+		lock = flags.String("lock", "", "lock file pinning raw sources to cached content, see the update command")
+	}
+	var watchInterval *time.Duration
+	if stage == stageGenerate {
+		watch = flags.Bool("watch", false, "keep running and regenerate rulesets affected by changed data files")
+		watchInterval = flags.Duration("watch-interval", time.Second, "interval of polling the data directory in -watch mode")
+	}
+
+	_ = flags.Parse(args)
+
+	if flags.NArg() != positional {
+		flags.Usage()
+
+		return nil, nil, errUsage
+	}
+
+	g := &generator{
+		stage:   stage,
+		only:    splitList(*only),
+		formats: splitList(*formatList),
+		fail:    *fail,
+		force:   *force,
+		updated: map[string]bool{},
+
+		header:    *header,
+		timestamp: *timestamp,
+	}
+
+	if watch != nil {
+		g.watch, g.watchInterval = *watch, *watchInterval
+	}
+
+	if lock != nil {
+		g.lock = *lock
+	}
+
+	if *reproducible {
+		if g.timestamp {
+			return nil, nil, errors.New("-timestamp conflicts with -reproducible")
+		}
+
+		if stage == stageRaw && g.lock == "" {
+			return nil, nil, errors.New("-reproducible requires -lock to pin raw sources")
+		}
+
+		// 网络上的数据随时可能变化，-previous 影响回归保护的结果，数据来源决定输出内容。
+		// 默认的上次输出为输出目录，其内容取决于此前的运行，因此需要明确指定
+		if *previous == "" {
+			return nil, nil, errors.New("-reproducible requires an explicit local -previous")
+		}
+
+		if dlc.IsURL(*previous) {
+			return nil, nil, errors.New("-reproducible does not fetch -previous from the network")
+		}
+
+		if stage == stageGenerate && dlc.IsURL(flags.Arg(0)) {
+			return nil, nil, errors.New("-reproducible does not fetch domain-list-community data from the network")
+		}
+	}
+
+	for _, format := range g.formats {
+		if !contains(formats, format) {
+			return nil, nil, fmt.Errorf("unknown format %s", format)
+		}
+	}
+
+	g.dir = flags.Arg(positional - 1)
+
+	if *previous == "" {
+		*previous = g.dir
+	}
+
+	g.previousOutput = openSnapshot(*previous)
+	g.previous = loadPrevious(g.previousOutput)
+	g.current = loadPrevious(dirSnapshot(g.dir))
+
+	if err := os.MkdirAll(g.dir, 0755); err != nil {
+		return nil, nil, err
+	}
+
+	return g, flags.Args(), nil
+}
+
+// selected 判断规则集 name 的 tag 是否需要输出
+func (g *generator) selected(name, tag string) bool {
+	if len(g.only) == 0 {
+		return true
+	}
+
+	for _, pattern := range g.only {
+		if matchOutput(pattern, name, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// write 将规则集按其需要的全部格式写入输出目录，规则排序后输出以保证结果稳定
+func (g *generator) write(p *provider) {
+	name := p.OutputName()
+
+	rules := append([]string{}, p.Rules...)
+	sort.Strings(rules)
+
+	if g.guard(p, len(rules)) {
+		return
+	}
+
+	if publishDomains != nil && p.Behavior == output.Domain {
+		if g.domains == nil {
+			g.domains = map[string][]string{}
+		}
+
+		g.domains[name+output.Clash{}.Extension()] = rules
+	}
+
+	stats := p.Stats
+	stats.Full, stats.Suffix = countDomains(p.Behavior, rules)
+
+	// 注释头随来源变化，计入输入以便其变化时重新输出
+	header := g.headerLines(p)
+	if p.Inputs != "" && g.header {
+		p.Inputs = g.inputs(append([]string{p.Inputs}, header...)...)
+	}
+
+	var generated *time.Time
+	if g.timestamp {
+		now := time.Now().UTC().Truncate(time.Second)
+		generated = &now
+
+		header = append(header, "Generated at: "+now.Format(time.RFC3339))
+	}
+
+	for _, writer := range writersOf(name, p.Behavior, g.formats) {
+		file := name + writer.Extension()
+		outputPath := path.Join(g.dir, file)
+
+		current := g.current[file]
+		if current != nil && g.onDisk(current) && !g.force {
+			// 输入未变化时无需重新输出，沿用上次的文件与统计
+			if p.Inputs != "" && current.Inputs == p.Inputs {
+				g.keep(p, current)
+
+				continue
+			}
+		} else {
+			current = nil
+		}
+
+		buf := &bytes.Buffer{}
+
+		if g.header {
+			if err := output.WriteHeader(buf, writer, header); err != nil {
+				g.report.add(fmt.Errorf("write %s: %w", outputPath, err))
+
+				continue
+			}
+		}
+
+		if err := writer.Write(buf, p.Behavior, rules); err != nil {
+			g.report.add(fmt.Errorf("write %s: %w", outputPath, err))
+
+			continue
+		}
+
+		if dropped := output.Dropped(writer, p.Behavior, rules); dropped > 0 {
+			slog.Warn("dropped rules the format cannot match exactly", "file", file, "count", dropped)
+		}
+
+		entry := manifest.NewEntry(file, buf.Bytes())
+		entry.Name = p.Name
+		entry.Tag = p.Tag
+		entry.Behavior = p.Behavior
+		entry.Format = writer.Name()
+		entry.Count = len(rules)
+		entry.Sources = p.Sources
+		entry.Commit = p.Commit
+		entry.Stage = g.stage
+		entry.Stats = &stats
+		entry.Inputs = p.Inputs
+		entry.Generator = generatorVersion()
+		entry.Generated = generated
+		entry.ETags = p.ETags
+
+		if current != nil && current.SHA256 == entry.SHA256 {
+			g.keep(p, entry)
+
+			continue
+		}
+
+		g.queueWrite(file, buf.Bytes(), entry)
+		g.updated[name] = true
+	}
+}
+
+// keep 记录内容未变化而未重新写入的文件，元数据按 p 更新
+func (g *generator) keep(p *provider, entry *manifest.Entry) {
+	kept := *entry
+	kept.Sources = p.Sources
+	kept.Commit = p.Commit
+	kept.Stage = g.stage
+	kept.Inputs = p.Inputs
+	kept.Generator = generatorVersion()
+	kept.ETags = p.ETags
+
+	slog.Debug("unchanged", "file", kept.File)
+
+	g.entries = append(g.entries, &kept)
+	g.unchanged++
+}
+
+// queueWrite 暂存将 content 写入输出目录中的 file，entry 随之加入索引
+func (g *generator) queueWrite(file string, content []byte, entry *manifest.Entry) {
+	g.pending = append(g.pending, &pendingFile{file: file, content: content, entry: entry})
+	g.entries = append(g.entries, entry)
+}
+
+// queueRemove 暂存删除输出目录中的 file
+func (g *generator) queueRemove(file string) {
+	g.pending = append(g.pending, &pendingFile{file: file})
+	g.removed = append(g.removed, file)
+}
+
+// flush 执行暂存的写入与删除，失败的文件不计入 entries 与 removed
+func (g *generator) flush() {
+	failed := map[string]bool{}
+
+	for _, p := range g.pending {
+		outputPath := path.Join(g.dir, p.file)
+
+		if p.content == nil {
+			if err := os.Remove(outputPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				g.report.add(fmt.Errorf("remove %s: %w", p.file, err))
+
+				failed[p.file] = true
+
+				continue
+			}
+
+			slog.Debug("removed", "file", p.file)
+
+			continue
+		}
+
+		if err := output.WriteFile(outputPath, p.content); err != nil {
+			g.report.add(fmt.Errorf("write %s: %w", outputPath, err))
+
+			failed[p.file] = true
+
+			continue
+		}
+
+		slog.Debug("wrote", "file", outputPath, "format", p.entry.Format, "rules", p.entry.Count)
+	}
+
+	g.pending = nil
+
+	if len(failed) == 0 {
+		return
+	}
+
+	entries := g.entries[:0]
+	for _, entry := range g.entries {
+		if !failed[entry.File] {
+			entries = append(entries, entry)
+		}
+	}
+
+	removed := g.removed[:0]
+	for _, file := range g.removed {
+		if !failed[file] {
+			removed = append(removed, file)
+		}
+	}
+
+	g.entries, g.removed = entries, removed
+}
+
+// onDisk 判断 entry 对应的文件是否仍在输出目录中且内容与索引中的 sha256 一致
+func (g *generator) onDisk(entry *manifest.Entry) bool {
+	content, err := os.ReadFile(path.Join(g.dir, entry.File))
+	if err != nil || len(content) != entry.Size {
+		return false
+	}
+
+	sum := sha256.Sum256(content)
+
+	return hex.EncodeToString(sum[:]) == entry.SHA256
+}
+
+// inputs 返回 parts 与影响输出内容的配置共同的 sha256，配置包括格式、DNS、classical 与聚合的声明、回归保护与程序本身
+func (g *generator) inputs(parts ...string) string {
+	h := sha256.New()
+
+	for _, part := range parts {
+		_, _ = fmt.Fprintln(h, part)
+	}
+
+	_, _ = fmt.Fprintf(h, "%v\n%+v\n%v\n%+v\n", g.formats, dnsOutputs, classicalOutputs, aggregates)
+
+	for _, gd := range guards {
+		_, _ = fmt.Fprintf(h, "%+v\n", *gd)
+	}
+
+	_, _ = fmt.Fprintf(h, "%s\n%s\n%v\n", generatorVersion(), buildFingerprint(), g.timestamp)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// headerLines 返回规则集 p 的注释头，不含生成时间
+func (g *generator) headerLines(p *provider) []string {
+	lines := []string{"Generated by " + generatorVersion()}
+
+	if p.Commit != "" {
+		lines = append(lines, "domain-list-community commit: "+p.Commit)
+	}
+
+	for _, url := range p.Sources {
+		line := "Source: " + url
+		if etag := p.ETags[url]; etag != "" {
+			line += " (ETag " + etag + ")"
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// logUpdated 输出本次内容有变化的规则集，并为下次增量生成记录索引
+func (g *generator) logUpdated() {
+	updated := make([]string, 0, len(g.updated))
+	for name := range g.updated {
+		updated = append(updated, name)
+	}
+
+	sort.Strings(updated)
+
+	slog.Info("updated providers", "count", len(updated), "providers", updated, "unchanged_files", g.unchanged)
+
+	for _, file := range g.removed {
+		delete(g.current, file)
+	}
+
+	for _, entry := range g.entries {
+		g.current[entry.File] = entry
+	}
+
+	g.updated, g.unchanged = map[string]bool{}, 0
+}
+
+// finish 写入暂存的文件，将其合并入 index.json 并输出错误汇总。
+// 未指定 -only 时视为完整生成，索引中该阶段未再生成的文件会被移除。
+// 有规则集触发 guardAbort 时不写入任何文件、不更新索引并返回错误
+func (g *generator) finish() error {
+	if g.aborted {
+		g.pending, g.domains = nil, nil
+
+		_ = g.report.summary(true)
+
+		return errors.New("aborted by regression guard")
+	}
+
+	g.flush()
+
+	replaceStage := ""
+	if len(g.only) == 0 {
+		replaceStage = g.stage
+	}
+
+	if err := updateIndex(g.dir, g.entries, g.removed, replaceStage); err != nil {
+		return err
+	}
+
+	g.logUpdated()
+	g.publish()
+
+	return g.report.summary(g.fail)
+}
+
+// publish 将写入索引的 domain 规则集交给 publishDomains
+func (g *generator) publish() {
+	if publishDomains != nil {
+		for _, entry := range g.entries {
+			if rules, ok := g.domains[entry.File]; ok {
+				publishDomains(entry.File, entry.SHA256, rules)
+			}
+		}
+	}
+
+	g.domains = nil
+}
+
+// updateIndex 将 entries 合并入输出目录中的 index.json，并移除已删除的文件 removed
+func updateIndex(dir string, entries []*manifest.Entry, removed []string, replaceStage string) error {
+	indexPath := path.Join(dir, manifest.FileName)
+
+	index, err := manifest.Load(indexPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		index = &manifest.Index{}
+	} else if err != nil {
+		return err
+	}
+
+	index.Remove(removed)
+	index.Merge(entries, replaceStage)
+
+	content, err := index.Marshal()
+	if err != nil {
+		return err
+	}
+
+	return output.WriteFile(indexPath, content)
+}
+
+// domainData 为 generate 读取的 domain-list-community 数据与上次生成的结果，watch 时按变化的文件增量更新
+type domainData struct {
+	dir        string
+	commit     string
+	ruleSets   map[string]*rule.Ruleset
+	resolved   map[string]map[string][]string // 规则集各标签解析出的域名，用于聚合
+	outputs    map[string]map[string]string   // 规则集写出的输出名到 behavior
+	aggregates map[string]string              // 聚合写出的规则的 sha256
+}
+
+func runGenerate(args []string) error {
+	g, positional, err := newGenerator(stageGenerate, args, 2)
+	if err != nil {
+		return err
+	}
+
+	source, err := dlc.Open(positional[0])
+	if err != nil {
+		return fmt.Errorf("open domains: %w", err)
+	}
+	defer source.Close()
+
+	if g.watch && source.Extracted {
+		return errors.New("-watch requires a domain-list-community directory")
+	}
+
+	d := &domainData{
+		dir:        source.Dir,
+		commit:     source.Commit,
+		resolved:   map[string]map[string][]string{},
+		outputs:    map[string]map[string]string{},
+		aggregates: map[string]string{},
+	}
+
+	start := time.Now()
+
+	d.ruleSets, err = rule.ParseDirectory(d.dir)
+	if err != nil {
+		return fmt.Errorf("load domains: %w", err)
+	}
+
+	slog.Info("parsed domains", "rulesets", len(d.ruleSets), "commit", d.commit, "duration", time.Since(start))
+
+	names := make([]string, 0, len(d.ruleSets))
+	for name := range d.ruleSets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, err := range d.ruleSets[name].Errors {
+			g.report.add(err)
+		}
+	}
+
+	start = time.Now()
+
+	g.writeRulesets(d, names)
+
+	slog.Info("resolved domains", "rulesets", len(names), "duration", time.Since(start))
+
+	g.writeAggregates(d)
+
+	err = g.finish()
+	if !g.watch {
+		return err
+	}
+
+	if err != nil {
+		slog.Error("generation failed", "err", err)
+	}
+
+	g.advancePrevious()
+
+	return g.watchData(d)
+}
+
+// writeRulesets 解析 names 中的规则集并写出其 domain 与 classical 输出。
+// 已不存在的规则集，以及不再有规则的标签，其上次写出的文件会被删除
+func (g *generator) writeRulesets(d *domainData, names []string) {
+	for _, name := range names {
+		outputs := map[string]string{}
+
+		if _, ok := d.ruleSets[name]; !ok {
+			delete(d.resolved, name)
+
+			g.removeStale(d, name, outputs)
+
+			continue
+		}
+
+		// 规则集只解析一次，classical 输出使用同一次解析的结果，错误只报告一次
+		resolution, err := rule.ResolveAll(d.ruleSets, name, hasClassical(name))
+		if err != nil {
+			g.report.add(fmt.Errorf("resolve %s: %w", name, err))
+
+			// 无法解析的规则集不再参与聚合，上次写出的文件被删除
+			delete(d.resolved, name)
+
+			g.removeStale(d, name, outputs)
+
+			continue
+		}
+
+		d.resolved[name] = resolution.Domains
+
+		var digests []string
+		for _, dependency := range rule.Dependencies(d.ruleSets, name) {
+			if set := d.ruleSets[dependency]; set != nil {
+				digests = append(digests, dependency+" "+set.Digest)
+			}
+		}
+
+		inputs := g.inputs(digests...)
+
+		for tag, rules := range resolution.Domains {
+			if !isClassical(name, tag) && g.selected(name, tag) {
+				p := &provider{
+					Name:     name,
+					Tag:      tag,
+					Behavior: output.Domain,
+					Rules:    rules,
+					Commit:   d.commit,
+					Inputs:   inputs,
+					Stats:    manifest.Stats{Deduplicated: resolution.Deduplicated[tag]},
+				}
+
+				g.write(p)
+
+				outputs[p.OutputName()] = p.Behavior
+			}
+		}
+
+		for tag, rules := range resolution.Classical {
+			if isClassical(name, tag) && g.selected(name, tag) {
+				p := &provider{
+					Name:     name,
+					Tag:      tag,
+					Behavior: output.Classical,
+					Rules:    rules,
+					Commit:   d.commit,
+					Inputs:   inputs,
+				}
+
+				g.write(p)
+
+				outputs[p.OutputName()] = p.Behavior
+			}
+		}
+
+		g.removeStale(d, name, outputs)
+	}
+}
+
+// removeStale 暂存删除规则集 name 上次写出、但不在 outputs 中的文件，并记录 outputs
+func (g *generator) removeStale(d *domainData, name string, outputs map[string]string) {
+	for outputName, behavior := range d.outputs[name] {
+		if _, ok := outputs[outputName]; ok {
+			continue
+		}
+
+		for _, writer := range writersOf(outputName, behavior, g.formats) {
+			g.queueRemove(outputName + writer.Extension())
+		}
+	}
+
+	if len(outputs) == 0 {
+		delete(d.outputs, name)
+	} else {
+		d.outputs[name] = outputs
+	}
+}
+
+// writeAggregates 根据全部规则集的解析结果计算聚合，只写出规则与上次写出时不同的聚合
+func (g *generator) writeAggregates(d *domainData) {
+	names := make([]string, 0, len(d.resolved))
+	for name := range d.resolved {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	aggregated := map[string]*trie.Trie{}
+	added := map[string]int{}
+
+	for _, name := range names {
+		for tag, rules := range d.resolved[name] {
+			collectAggregates(aggregated, added, name, tag, rules)
+		}
+	}
+
+	for _, a := range aggregates {
+		if !g.selected(a.Name, "") {
+			continue
+		}
+
+		// 成员可能随上游数据的调整而暂时不存在，不应使整次生成失败
+		domains, ok := aggregated[a.Name]
+		if !ok {
+			slog.Warn("skip empty aggregate", "name", a.Name, "members", a.Members)
+
+			continue
+		}
+
+		rules := domains.Dump()
+		sort.Strings(rules)
+
+		sum := sha256.Sum256([]byte(strings.Join(rules, "\n")))
+		hash := hex.EncodeToString(sum[:])
+
+		if d.aggregates[a.Name] == hash {
+			continue
+		}
+
+		d.aggregates[a.Name] = hash
+
+		g.write(&provider{
+			Name:     a.Name,
+			Behavior: output.Domain,
+			Rules:    rules,
+			Commit:   d.commit,
+			Stats:    manifest.Stats{Deduplicated: added[a.Name] - len(rules)},
+		})
+	}
+}
+
+func runRaw(args []string) error {
+	g, _, err := newGenerator(stageRaw, args, 1)
+	if err != nil {
+		return err
+	}
+
+	var selected []*raw.Raw
+	for _, r := range raws {
+		if g.selected(r.Name, "") {
+			selected = append(selected, r)
+		}
+	}
+
+	var lock *raw.Lock
+	if g.lock != "" {
+		lock, err = raw.LoadLock(g.lock)
+		if err != nil {
+			return fmt.Errorf("load lock: %w", err)
+		}
+	}
+
+	ruleSets, failures := raw.LoadRawSources(selected, lock)
+	for _, err := range failures {
+		g.report.add(err)
+	}
+
+	// 读取失败的规则集沿用上次的输出，否则完整生成时它会从索引中移除，文件却仍留在输出目录
+	loaded := map[string]bool{}
+	for _, r := range ruleSets {
+		loaded[r.Name] = true
+	}
+
+	for _, r := range selected {
+		if !loaded[r.Name] {
+			slog.Warn("keep previous output", "provider", r.Name, "err", "failed to load sources")
+
+			g.keepPrevious(r.Name, r.Behavior, fmt.Errorf("load %s failed", r.Name))
+		}
+	}
+
+	for _, r := range ruleSets {
+		var sources []string
+		sources = append(sources, r.SourceUrl...)
+		sources = append(sources, r.BlacklistUrl...)
+		sources = append(sources, r.ForceIncludeUrl...)
+
+		g.write(&provider{
+			Name:     r.Name,
+			Behavior: r.Behavior,
+			Rules:    r.Rules,
+			Sources:  sources,
+			Inputs:   g.inputs(r.Digest, fmt.Sprintf("%+v", *r.Raw)),
+			ETags:    r.ETags,
+			Stats: manifest.Stats{
+				Invalid:       r.Stats.Invalid,
+				Deduplicated:  r.Stats.Deduplicated,
+				Blacklisted:   r.Stats.Blacklisted,
+				ForceIncluded: r.Stats.ForceIncluded,
+				Sources:       countSources(r.Origins),
+			},
+		})
+	}
+
+	return g.finish()
+}
+
+func contains(list []string, item string) bool {
+	for _, i := range list {
+		if i == item {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kr328/domains2providers/manifest"
+	"github.com/kr328/domains2providers/output"
+	"github.com/kr328/domains2providers/rule"
+)
+
+// newTestGenerator 创建输出到 dir 的 generator，上次的输出同样为 dir
+func newTestGenerator(dir string) *generator {
+	return &generator{
+		dir:            dir,
+		stage:          stageGenerate,
+		updated:        map[string]bool{},
+		current:        loadPrevious(dirSnapshot(dir)),
+		previous:       loadPrevious(dirSnapshot(dir)),
+		previousOutput: dirSnapshot(dir),
+		fail:           true,
+	}
+}
+
+func TestGuardAbortWritesNothing(t *testing.T) {
+	defer func(saved []*guard) { guards = saved }(guards)
+
+	guards = []*guard{{Pattern: "guarded", MinEntries: 2, Action: guardAbort}}
+
+	dir := t.TempDir()
+
+	// 上次的输出
+	g := newTestGenerator(dir)
+	g.write(&provider{Name: "kept", Behavior: output.Domain, Rules: []string{"+.old.com"}})
+	g.write(&provider{Name: "stale", Behavior: output.Domain, Rules: []string{"+.stale.com"}})
+	g.write(&provider{Name: "guarded", Behavior: output.Domain, Rules: []string{"+.a.com", "+.b.com"}})
+	if err := g.finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	before := readDir(t, dir)
+
+	g = newTestGenerator(dir)
+	g.write(&provider{Name: "kept", Behavior: output.Domain, Rules: []string{"+.new.com"}})
+	g.write(&provider{Name: "added", Behavior: output.Domain, Rules: []string{"+.added.com"}})
+	g.queueRemove("stale.yaml")
+	g.write(&provider{Name: "guarded", Behavior: output.Domain, Rules: []string{"+.a.com"}})
+
+	if err := g.finish(); err == nil {
+		t.Fatal("expected the regression guard to abort")
+	}
+
+	after := readDir(t, dir)
+
+	if len(after) != len(before) {
+		t.Errorf("files = %d, want %d", len(after), len(before))
+	}
+
+	for file, content := range before {
+		if after[file] != content {
+			t.Errorf("%s changed after abort", file)
+		}
+	}
+}
+
+func TestFinishWritesStagedFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	g := newTestGenerator(dir)
+	g.write(&provider{Name: "a", Behavior: output.Domain, Rules: []string{"+.a.com"}})
+
+	if _, err := os.Stat(filepath.Join(dir, "a.yaml")); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("a.yaml written before finish: %v", err)
+	}
+
+	if err := g.finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "a.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "payload:\n  - \"+.a.com\"\n"; string(content) != want {
+		t.Errorf("a.yaml = %q, want %q", content, want)
+	}
+
+	index, err := manifest.Load(filepath.Join(dir, manifest.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(index.Files) != 1 || index.Files[0].File != "a.yaml" {
+		t.Errorf("index = %+v", index.Files)
+	}
+}
+
+// readDir 返回目录中全部文件的内容
+func readDir(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{}
+
+	for _, entry := range entries {
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files[entry.Name()] = string(content)
+	}
+
+	return files
+}
+
+func TestResolveErrorRemovesOutputs(t *testing.T) {
+	data, dir := t.TempDir(), t.TempDir()
+
+	writeData := func(content string) map[string]*rule.Ruleset {
+		if err := os.WriteFile(filepath.Join(data, "a"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		ruleSets, err := rule.ParseDirectory(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return ruleSets
+	}
+
+	d := &domainData{
+		dir:        data,
+		resolved:   map[string]map[string][]string{},
+		outputs:    map[string]map[string]string{},
+		aggregates: map[string]string{},
+		ruleSets:   writeData("example.com\n"),
+	}
+
+	g := newTestGenerator(dir)
+	g.writeRulesets(d, []string{"a"})
+	if err := g.finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	d.ruleSets = writeData("include:missing\n")
+
+	// classical 输出与 domain 输出共用一次解析，错误只报告一次
+	defer func(saved []string) { classicalOutputs = saved }(classicalOutputs)
+	classicalOutputs = []string{"a@ads"}
+
+	g = newTestGenerator(dir)
+	g.fail = false
+	g.writeRulesets(d, []string{"a"})
+	if len(g.report.errors) != 1 {
+		t.Errorf("errors = %v, want one resolve error", g.report.errors)
+	}
+	if err := g.finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := d.resolved["a"]; ok {
+		t.Error("unresolvable ruleset still aggregated")
+	}
+
+	if _, ok := d.outputs["a"]; ok {
+		t.Error("outputs of unresolvable ruleset still recorded")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "a.yaml")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("a.yaml not removed: %v", err)
+	}
+}
+
+func TestAdvancePrevious(t *testing.T) {
+	defer func(saved []*guard) { guards = saved }(guards)
+
+	guards = []*guard{{Pattern: "a", MaxChange: 50, Action: guardAbort}}
+
+	dir := t.TempDir()
+
+	rules := []string{"+.a.com", "+.b.com", "+.c.com", "+.d.com"}
+
+	g := newTestGenerator(dir)
+	g.write(&provider{Name: "a", Behavior: output.Domain, Rules: rules})
+	if err := g.finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	g = newTestGenerator(dir)
+
+	// 每次更新增加不超过 50%，累计超过 50%，与最近一次的输出比较时不触发回归保护
+	for _, domain := range []string{"+.e.com", "+.f.com", "+.g.com"} {
+		g.advancePrevious()
+
+		rules = append(rules, domain)
+
+		g.entries, g.removed = nil, nil
+		g.write(&provider{Name: "a", Behavior: output.Domain, Rules: rules})
+		if err := g.finish(); err != nil {
+			t.Fatalf("%d rules: %v", len(rules), err)
+		}
+	}
+}
+
+func TestUnchangedInputsRewriteModifiedFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func() {
+		g := newTestGenerator(dir)
+		g.write(&provider{Name: "a", Behavior: output.Domain, Rules: []string{"+.a.com"}, Inputs: g.inputs("a")})
+		if err := g.finish(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write()
+
+	file := filepath.Join(dir, "a.yaml")
+
+	want, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 大小不变的修改同样需要重新输出
+	if err := os.WriteFile(file, bytes.ReplaceAll(want, []byte("a.com"), []byte("b.com")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	write()
+
+	if content, err := os.ReadFile(file); err != nil || !bytes.Equal(content, want) {
+		t.Errorf("a.yaml = %q, %v, want %q", content, err, want)
+	}
+}
+
+func TestInputsCoverConfig(t *testing.T) {
+	defer func(saved []*guard) { guards = saved }(guards)
+	defer func(saved []aggregate) { aggregates = saved }(aggregates)
+
+	g := newTestGenerator(t.TempDir())
+	base := g.inputs("a")
+
+	guards = []*guard{{Pattern: "a", MinEntries: 1, Action: guardSkip}}
+
+	withGuards := g.inputs("a")
+	if withGuards == base {
+		t.Error("inputs unchanged after changing guards")
+	}
+
+	aggregates = append(aggregates, aggregate{Name: "extra", Members: []string{"a"}})
+
+	if g.inputs("a") == withGuards {
+		t.Error("inputs unchanged after changing aggregates")
+	}
+}
+
+func TestReproducibleRejectsNetwork(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		stage      string
+		args       []string
+		positional int
+	}{
+		{stageGenerate, []string{"-reproducible", "-timestamp", "data", dir}, 2},
+		{stageGenerate, []string{"-reproducible", "https://example.com/dlc.zip", dir}, 2},
+		{stageGenerate, []string{"-reproducible", "-previous", "https://example.com/generated", "data", dir}, 2},
+		{stageGenerate, []string{"-reproducible", "data", dir}, 2},
+		{stageRaw, []string{"-reproducible", "-previous", dir, dir}, 1},
+		{stageRaw, []string{"-reproducible", "-lock", "sources.lock", dir}, 1},
+		{stageRaw, []string{"-reproducible", "-lock", "sources.lock", "-previous", "https://example.com/generated", dir}, 1},
+	}
+
+	for _, test := range tests {
+		if _, _, err := newGenerator(test.stage, test.args, test.positional); err == nil {
+			t.Errorf("%s %v succeeded", test.stage, test.args)
+		}
+	}
+
+	if _, _, err := newGenerator(stageGenerate, []string{"-reproducible", "-previous", dir, "data", dir}, 2); err != nil {
+		t.Errorf("local data rejected: %v", err)
+	}
+}
+
+func TestEmptyAggregateIsNotAnError(t *testing.T) {
+	defer func(saved []aggregate) { aggregates = saved }(aggregates)
+
+	aggregates = []aggregate{{Name: "games", Members: []string{"category-games"}}}
+
+	d := &domainData{
+		resolved:   map[string]map[string][]string{"a": {"": {"+.example.com"}}},
+		outputs:    map[string]map[string]string{},
+		aggregates: map[string]string{},
+	}
+
+	g := newTestGenerator(t.TempDir())
+	g.writeAggregates(d)
+
+	if len(g.report.errors) != 0 {
+		t.Errorf("errors = %v", g.report.errors)
+	}
+}
@@ -1,3 +1,7 @@
 module github.com/kr328/domains2providers
 
-go 1.18
+go 1.21
+
+require github.com/andybalholm/brotli v1.1.1
+
+require github.com/klauspost/compress v1.17.9
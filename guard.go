@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math"
+
+	"github.com/kr328/domains2providers/manifest"
+	"github.com/kr328/domains2providers/output"
+)
+
+// regressionError 表示规则集触发了回归保护
+type regressionError struct {
+	Provider string
+	Reason   string
+}
+
+func (e *regressionError) Error() string {
+	return fmt.Sprintf("regression guard of %s: %s", e.Provider, e.Reason)
+}
+
+// guardOf 返回规则集 name 的 tag 使用的 guard，没有时返回 nil
+func guardOf(name, tag string) *guard {
+	for _, g := range guards {
+		if matchOutput(g.Pattern, name, tag) {
+			return g
+		}
+	}
+
+	return nil
+}
+
+// check 检查 count 条规则是否触发回归保护，返回原因。previous 为上次输出的条数，小于 0 时只检查 MinEntries
+func (g *guard) check(count, previous int) string {
+	if count < g.MinEntries {
+		return fmt.Sprintf("%d entries, below minimum %d", count, g.MinEntries)
+	}
+
+	if g.MaxChange > 0 && previous > 0 {
+		change := float64(count-previous) / float64(previous) * 100
+		if math.Abs(change) > g.MaxChange {
+			return fmt.Sprintf("%d entries, %+.1f%% from previous %d, max %g%%", count, change, previous, g.MaxChange)
+		}
+	}
+
+	return ""
+}
+
+// loadPrevious 读取上次输出的 index.json，没有上次输出时返回空的索引
+func loadPrevious(s snapshot) map[string]*manifest.Entry {
+	entries := map[string]*manifest.Entry{}
+
+	content, err := s.Read(manifest.FileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return entries
+	} else if err != nil {
+		slog.Warn("previous output unavailable, only minimum entries are checked", "err", err)
+
+		return entries
+	}
+
+	index := &manifest.Index{}
+	if err := json.Unmarshal(content, index); err != nil {
+		slog.Warn("previous output unavailable, only minimum entries are checked", "err", err)
+
+		return entries
+	}
+
+	for _, entry := range index.Files {
+		entries[entry.File] = entry
+	}
+
+	return entries
+}
+
+// guard 检查规则集是否触发回归保护，触发时按 Action 处理并返回 true
+func (g *generator) guard(p *provider, count int) bool {
+	name := p.OutputName()
+
+	gd := guardOf(p.Name, p.Tag)
+	if gd == nil {
+		return false
+	}
+
+	previous := -1
+	if entry := g.previous[name+output.Clash{}.Extension()]; entry != nil {
+		previous = entry.Count
+	}
+
+	reason := gd.check(count, previous)
+	if reason == "" {
+		return false
+	}
+
+	err := &regressionError{Provider: name, Reason: reason}
+
+	switch gd.Action {
+	case guardSkip:
+		slog.Warn("keep previous output", "provider", name, "err", err)
+
+		g.keepPrevious(name, p.Behavior, err)
+	default:
+		g.report.add(err)
+
+		g.aborted = true
+	}
+
+	return true
+}
+
+// keepPrevious 将规则集上次输出的文件复制到输出目录，并沿用其索引。没有上次输出时规则集不会被输出，reason 作为错误记录
+func (g *generator) keepPrevious(name, behavior string, reason error) {
+	for _, writer := range writersOf(name, behavior, g.formats) {
+		file := name + writer.Extension()
+
+		entry := g.previous[file]
+		if entry == nil {
+			g.report.add(fmt.Errorf("%w, no previous %s", reason, file))
+
+			continue
+		}
+
+		if current := g.current[file]; current != nil && current.SHA256 == entry.SHA256 && g.onDisk(current) {
+			kept := *entry
+
+			g.entries = append(g.entries, &kept)
+
+			continue
+		}
+
+		content, err := g.previousOutput.Read(file)
+		if err != nil {
+			g.report.add(fmt.Errorf("%w, read previous %s: %v", reason, file, err))
+
+			continue
+		}
+
+		kept := *entry
+
+		g.queueWrite(file, content, &kept)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/kr328/domains2providers/dlc"
+	"github.com/kr328/domains2providers/rule"
+)
+
+func runLint(args []string) error {
+	flags := newFlagSet("lint")
+
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+
+		return errUsage
+	}
+
+	source, err := dlc.Open(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("open domains: %w", err)
+	}
+	defer source.Close()
+
+	diagnostics, err := rule.Lint(source.Dir, selectsAttribute)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+
+	for _, d := range diagnostics {
+		_, _ = fmt.Fprintln(w, d.String())
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(diagnostics) > 0 {
+		return fmt.Errorf("%d problems found", len(diagnostics))
+	}
+
+	return nil
+}
+
+// selectsAttribute 判断配置中的输出是否选中规则集 name 的属性 attribute：
+// 聚合的成员、classicalOutputs、DNS 输出、回归保护、冲突检查与 Clash 配置中的 name@attribute 或 @attribute
+func selectsAttribute(name, attribute string) bool {
+	var patterns []string
+
+	for _, a := range aggregates {
+		patterns = append(patterns, a.Members...)
+	}
+
+	for _, o := range dnsOutputs {
+		patterns = append(patterns, o.Name)
+	}
+
+	for _, g := range guards {
+		patterns = append(patterns, g.Pattern)
+	}
+
+	for _, pair := range conflictPairs {
+		patterns = append(patterns, pair[0], pair[1])
+	}
+
+	for _, r := range clashRules {
+		patterns = append(patterns, r.Provider)
+	}
+
+	patterns = append(patterns, classicalOutputs...)
+
+	for _, pattern := range patterns {
+		if matchOutput(pattern, name, attribute) {
+			return true
+		}
+	}
+
+	return false
+}
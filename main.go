@@ -1,113 +1,258 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
-	"path"
-	"github.com/kr328/domains2providers/raw"
-	"github.com/kr328/domains2providers/rule"
+	"runtime/debug"
+	"strings"
+	"sync"
 )
 
-func main() {
-	if len(os.Args) < 3 {
-		println("Usage: <v2ray-domains-path> <output-path>")
+// errUsage 表示参数错误，子命令已输出用法
+var errUsage = errors.New("invalid arguments")
 
-		os.Exit(1)
-	}
+// version 为程序版本，发布时通过 -ldflags "-X main.version=..." 设置
+var version = "dev"
 
-	data := path.Join(os.Args[1], "data")
-	generated := os.Args[2]
+// logLevel 为全部子命令共用的日志级别，由 -log-level 设置
+var logLevel = new(slog.LevelVar)
 
-	_ = os.MkdirAll(generated, 0755)
+// command 描述一个子命令
+type command struct {
+	Name  string
+	Usage string // 参数说明，如 [flags] <output-path>
+	Help  string
+	Run   func(args []string) error
+}
 
-	ruleSets, err := rule.ParseDirectory(data)
-	if err != nil {
-		println("Load domains: " + err.Error())
+var commands []*command
 
-		os.Exit(1)
+func init() {
+	commands = []*command{
+		{
+			Name:  "generate",
+			Usage: "[flags] <v2ray-domains-path> <output-path>",
+			Help:  "generate providers from domain-list-community data (offline)",
+			Run:   runGenerate,
+		},
+		{
+			Name:  "raw",
+			Usage: "[flags] <output-path>",
+			Help:  "generate providers from raw sources (network)",
+			Run:   runRaw,
+		},
+		{
+			Name:  "update",
+			Usage: "[flags] <lock-path>",
+			Help:  "fetch raw sources and pin their content in the lock file (network)",
+			Run:   runUpdate,
+		},
+		{
+			Name:  "config",
+			Usage: "[flags] <output-path>",
+			Help:  "generate clash config referencing generated providers",
+			Run:   runConfig,
+		},
+		{
+			Name:  "serve",
+			Usage: "[flags] <v2ray-domains-path> <output-path>",
+			Help:  "generate providers on a schedule and serve the output path over http",
+			Run:   runServe,
+		},
+		{
+			Name:  "resolve",
+			Usage: "[flags] <v2ray-domains-path> <name>",
+			Help:  "print resolved rules of a domain-list-community ruleset",
+			Run:   runResolve,
+		},
+		{
+			Name:  "match",
+			Usage: "[flags] [host...]",
+			Help:  "print providers and rules matching hosts, reads hosts from stdin if none given",
+			Run:   runMatch,
+		},
+		{
+			Name:  "simulate",
+			Usage: "-config <clash-config> -output <output-path> [flags] [host...]",
+			Help:  "print the first clash rule matching hosts, reads hosts from stdin if none given",
+			Run:   runSimulate,
+		},
+		{
+			Name:  "diff",
+			Usage: "[flags] <old-output-path> <new-output-path> | -ref <ref> [-repo <path>] <new-output-path>",
+			Help:  "report added, removed and subsumed rules between two generations",
+			Run:   runDiff,
+		},
+		{
+			Name:  "conflicts",
+			Usage: "[flags]",
+			Help:  "report domains matched by both rulesets of conflict_pairs and the sources of each side",
+			Run:   runConflicts,
+		},
+		{
+			Name:  "stats",
+			Usage: "[flags] <output-path>",
+			Help:  "print entry counts, dedup, blacklist and per source statistics of generated providers",
+			Run:   runStats,
+		},
+		{
+			Name:  "lint",
+			Usage: "<v2ray-domains-path>",
+			Help:  "check domain-list-community style data for mistakes",
+			Run:   runLint,
+		},
 	}
+}
 
-	//ad
-	adMap := make(map[string]int)
+func main() {
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel})))
 
+	if len(os.Args) < 2 {
+		usage()
 
-	for name := range ruleSets {
-		tags, err := rule.Resolve(ruleSets, name)
-		if err != nil {
-			println("Resolve " + name + ": " + err.Error())
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
 
+	for _, c := range commands {
+		if c.Name != name {
 			continue
 		}
 
-		for tag, rules := range tags {
-			var outputPath string
-
-			if tag == "" {
-				outputPath = path.Join(generated, fmt.Sprintf("%s.yaml", name))
-			} else {
-				outputPath = path.Join(generated, fmt.Sprintf("%s@%s.yaml", name, tag))
+		if err := c.Run(os.Args[2:]); err != nil {
+			if errors.Is(err, errUsage) {
+				os.Exit(2)
 			}
 
-			
+			slog.Error("command failed", "command", c.Name, "err", err)
 
-			file, err := os.OpenFile(outputPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-			if err != nil {
-				println("Write file " + outputPath + ": " + err.Error())
+			os.Exit(1)
+		}
 
-				continue
-			}
+		return
+	}
+
+	if name != "help" && name != "-h" && name != "--help" {
+		println("Unknown command: " + name)
+	}
+
+	usage()
+
+	os.Exit(2)
+}
+
+func usage() {
+	println("Usage: <command> [flags] [arguments]")
+	println()
+	println("Commands:")
+
+	for _, c := range commands {
+		println(fmt.Sprintf("  %-10s %s", c.Name, c.Help))
+	}
+}
 
-			_, _ = file.WriteString(fmt.Sprintf("payload:\n"))
-
-			
-			for _, domain := range rules {
-				_, _ = file.WriteString(fmt.Sprintf("  - \"%s\"\n", domain))
-				if tag == "ads" {
-					adMap[domain] = 1
-				}
-				if name == "category-ads-all" {
-					adMap[domain] = 1
-				}
+// newFlagSet 创建子命令 name 的 FlagSet，并在 -h 时输出子命令的用法
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		for _, c := range commands {
+			if c.Name == name {
+				println("Usage: " + c.Name + " " + c.Usage)
+				println()
+				println(c.Help)
+				println()
 			}
-			_ = file.Close()
 		}
+
+		flags.PrintDefaults()
 	}
 
-	raws, err := raw.LoadRawSources()
-	if err != nil {
-		println("Load raw resources: " + err.Error())
+	flags.Func("log-format", "log format: text or json (default text)", setLogFormat)
+	flags.Func("log-level", "log level: debug, info, warn or error (default info)", func(value string) error {
+		return logLevel.UnmarshalText([]byte(value))
+	})
+	flags.BoolFunc("v", "deprecated alias of -log-level debug", func(string) error {
+		logLevel.Set(slog.LevelDebug)
+
+		slog.Warn("-v is deprecated, use -log-level debug")
+
+		return nil
+	})
+	flags.Func("providers", "json file declaring raws and aggregates (default the embedded providers.json)", loadProviders)
+
+	return flags
+}
 
-		os.Exit(1)
+// setLogFormat 按 format 替换默认的日志输出
+func setLogFormat(format string) error {
+	options := &slog.HandlerOptions{Level: logLevel}
+
+	switch format {
+	case "text":
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, options)))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, options)))
+	default:
+		return fmt.Errorf("unknown log format %s", format)
 	}
 
-	for _, r := range raws {
-		outputPath := path.Join(generated, r.Name+".yaml")
+	return nil
+}
 
-		file, err := os.OpenFile(outputPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-		if err != nil {
-			println("Write file " + outputPath + ": " + err.Error())
+// generatorVersion 返回写入输出文件头与索引的程序名称与版本，未设置 version 时使用构建时的 git 提交
+func generatorVersion() string {
+	v := version
 
-			continue
+	if info, ok := debug.ReadBuildInfo(); ok && v == "dev" {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" && len(setting.Value) >= 12 {
+				v = setting.Value[:12]
+			}
 		}
+	}
 
-		_, _ = file.WriteString(fmt.Sprintf("payload:\n"))
+	return "V2rayDomains2Clash " + v
+}
 
-		for _, domain := range r.Rules {
-			_, _ = file.WriteString(fmt.Sprintf("  - \"%s\"\n", domain))
-		}
+// buildFingerprint 返回当前程序文件的 sha256，用于输入的 sha256。
+// go run 时 version 为 dev 且没有 vcs 信息，修改源码后只有程序文件会变化；读取失败时使用 generatorVersion
+var buildFingerprint = sync.OnceValue(func() string {
+	executable, err := os.Executable()
+	if err != nil {
+		return generatorVersion()
+	}
 
-		_ = file.Close()
+	file, err := os.Open(executable)
+	if err != nil {
+		return generatorVersion()
 	}
+	defer file.Close()
 
-	//ad
-	var adPath = path.Join(generated, fmt.Sprintf("ads.yaml"))
-	adFile, adErr := os.OpenFile(adPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-	if adErr != nil {
-		println("Write file " + adPath + ": " + adErr.Error())
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return generatorVersion()
 	}
-	_, _ = adFile.WriteString(fmt.Sprintf("payload:\n"))
-	for domain := range adMap {
-		_, _ = adFile.WriteString(fmt.Sprintf("  - \"%s\"\n", domain))
+
+	return hex.EncodeToString(h.Sum(nil))
+})
+
+// splitList 将逗号分隔的参数拆分为列表，忽略空项
+func splitList(value string) []string {
+	var items []string
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
 	}
-	_ = adFile.Close()
+
+	return items
 }
@@ -0,0 +1,103 @@
+package manifest
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrNotRepository = errors.New("not a git repository")
+)
+
+// GitCommit 读取 repo 目录中 git 仓库当前检出的提交，不依赖 git 命令
+func GitCommit(repo string) (string, error) {
+	gitDir, err := findGitDir(repo)
+	if err != nil {
+		return "", err
+	}
+
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return "", err
+	}
+
+	ref := strings.TrimSpace(string(head))
+	if !strings.HasPrefix(ref, "ref: ") {
+		return ref, nil
+	}
+
+	ref = strings.TrimPrefix(ref, "ref: ")
+
+	// worktree 的 refs 保存在 commondir 指向的主仓库中
+	if common, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		dir := strings.TrimSpace(string(common))
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(gitDir, dir)
+		}
+
+		gitDir = dir
+	}
+
+	if commit, err := os.ReadFile(filepath.Join(gitDir, filepath.FromSlash(ref))); err == nil {
+		return strings.TrimSpace(string(commit)), nil
+	}
+
+	return readPackedRef(gitDir, ref)
+}
+
+// findGitDir 返回 repo 的 .git 目录，.git 为文件时（子模块、worktree）读取其中的 gitdir
+func findGitDir(repo string) (string, error) {
+	gitDir := filepath.Join(repo, ".git")
+
+	stat, err := os.Stat(gitDir)
+	if err != nil {
+		return "", ErrNotRepository
+	}
+
+	if stat.IsDir() {
+		return gitDir, nil
+	}
+
+	content, err := os.ReadFile(gitDir)
+	if err != nil {
+		return "", err
+	}
+
+	dir := strings.TrimSpace(string(content))
+	if !strings.HasPrefix(dir, "gitdir: ") {
+		return "", ErrNotRepository
+	}
+
+	dir = strings.TrimPrefix(dir, "gitdir: ")
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(repo, dir)
+	}
+
+	return dir, nil
+}
+
+// readPackedRef 从 packed-refs 中查找 ref 对应的提交
+func readPackedRef(gitDir, ref string) (string, error) {
+	file, err := os.Open(filepath.Join(gitDir, "packed-refs"))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == ref {
+			return fields[0], nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", errors.New("ref " + ref + " not found")
+}
@@ -0,0 +1,141 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const FileName = "index.json"
+
+// Entry 描述一个生成的文件
+type Entry struct {
+	File     string   `json:"file"`
+	Name     string   `json:"name"`
+	Tag      string   `json:"tag,omitempty"`
+	Behavior string   `json:"behavior,omitempty"`
+	Format   string   `json:"format"`
+	Count    int      `json:"count"`
+	SHA256   string   `json:"sha256"`
+	Size     int      `json:"size"`
+	Sources  []string `json:"sources,omitempty"` // 原始规则的来源地址
+	Commit   string   `json:"commit,omitempty"`  // 生成所用的 domain-list-community 提交
+	Stage    string   `json:"stage"`             // 生成该文件的阶段，如 generate、raw
+	Stats    *Stats   `json:"stats,omitempty"`
+	Inputs   string   `json:"inputs,omitempty"` // 生成该文件的输入（数据文件或来源内容与相关配置）的 sha256
+
+	Generator string            `json:"generator,omitempty"` // 生成该文件的程序版本
+	Generated *time.Time        `json:"generated,omitempty"` // 生成时间，仅指定 -timestamp 时记录
+	ETags     map[string]string `json:"etags,omitempty"`     // 来源URL到获取时响应的 ETag
+}
+
+// Stats 为规则集的统计，Full 与 Suffix 为完整域名与后缀规则的条数，Blacklisted、ForceIncluded 与 Sources 仅原始规则有效
+type Stats struct {
+	Full          int            `json:"full"`
+	Suffix        int            `json:"suffix"`
+	Invalid       int            `json:"invalid,omitempty"`        // 无法解析为规则而跳过的行数
+	Deduplicated  int            `json:"deduplicated,omitempty"`   // 重复或被后缀规则覆盖而移除的条数
+	Blacklisted   int            `json:"blacklisted,omitempty"`    // 黑名单移除的条数
+	ForceIncluded int            `json:"force_included,omitempty"` // 强制纳入新增的条数
+	Sources       map[string]int `json:"sources,omitempty"`        // 每个来源URL贡献的规则条数
+}
+
+// Index 为输出目录中 index.json 的内容
+type Index struct {
+	Files []*Entry `json:"files"`
+}
+
+// NewEntry 根据文件内容创建 Entry 并计算 sha256 与大小
+func NewEntry(file string, content []byte) *Entry {
+	sum := sha256.Sum256(content)
+
+	return &Entry{
+		File:   file,
+		SHA256: hex.EncodeToString(sum[:]),
+		Size:   len(content),
+	}
+}
+
+// Load 读取 index.json
+func Load(path string) (*Index, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &Index{}
+	if err := json.Unmarshal(content, index); err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+// Merge 将 entries 合并入索引：同名文件被替换，replaceStage 非空时该阶段原有的其他文件一并移除
+func (i *Index) Merge(entries []*Entry, replaceStage string) {
+	written := map[string]bool{}
+	for _, entry := range entries {
+		written[entry.File] = true
+	}
+
+	files := make([]*Entry, 0, len(i.Files)+len(entries))
+
+	for _, entry := range i.Files {
+		if written[entry.File] || (replaceStage != "" && entry.Stage == replaceStage) {
+			continue
+		}
+
+		files = append(files, entry)
+	}
+
+	i.Files = append(files, entries...)
+}
+
+// Remove 从索引中移除 files
+func (i *Index) Remove(files []string) {
+	removed := map[string]bool{}
+	for _, file := range files {
+		removed[file] = true
+	}
+
+	kept := i.Files[:0]
+
+	for _, entry := range i.Files {
+		if !removed[entry.File] {
+			kept = append(kept, entry)
+		}
+	}
+
+	i.Files = kept
+}
+
+// Behaviors 返回 clash 格式文件对应的规则集名称（含标签）到 behavior 的映射
+func (i *Index) Behaviors() map[string]string {
+	behaviors := map[string]string{}
+
+	for _, entry := range i.Files {
+		if entry.Format == "clash" {
+			behaviors[strings.TrimSuffix(entry.File, ".yaml")] = entry.Behavior
+		}
+	}
+
+	return behaviors
+}
+
+// Marshal 按文件名排序后输出 json
+func (i *Index) Marshal() ([]byte, error) {
+	sort.Slice(i.Files, func(a, b int) bool {
+		return i.Files[a].File < i.Files[b].File
+	})
+
+	content, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(content, '\n'), nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/kr328/domains2providers/match"
+)
+
+func runMatch(args []string) error {
+	flags := newFlagSet("match")
+	data := flags.String("data", "", "domain-list-community path, match resolved rulesets")
+	dir := flags.String("output", "", "output path, match generated providers listed in index.json")
+	fetch := flags.Bool("raw", false, "download raw sources and match them")
+
+	_ = flags.Parse(args)
+
+	if *data == "" && *dir == "" && !*fetch {
+		flags.Usage()
+
+		return errUsage
+	}
+
+	var providers []*provider
+
+	if *dir != "" {
+		loaded, err := loadOutputProviders(*dir)
+		if err != nil {
+			return err
+		}
+
+		providers = append(providers, loaded...)
+	}
+
+	if *data != "" {
+		resolved, err := resolveProviders(*data)
+		if err != nil {
+			return err
+		}
+
+		providers = append(providers, resolved...)
+	}
+
+	if *fetch {
+		loaded, err := loadRawProviders()
+		if err != nil {
+			return err
+		}
+
+		providers = append(providers, loaded...)
+	}
+
+	sets := make([]*match.Set, len(providers))
+	for i, p := range providers {
+		sets[i] = match.New(p.Behavior, p.Rules)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	check := func(host string) {
+		matched := false
+
+		for i, set := range sets {
+			if entry, ok := set.Match(host); ok {
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", host, providers[i].OutputName(), entry)
+
+				matched = true
+			}
+		}
+
+		if !matched {
+			_, _ = fmt.Fprintf(w, "%s\t-\t-\n", host)
+		}
+	}
+
+	hosts := flags.Args()
+	if len(hosts) == 0 || (len(hosts) == 1 && hosts[0] == "-") {
+		return readHosts(os.Stdin, check)
+	}
+
+	for _, host := range hosts {
+		check(host)
+	}
+
+	return nil
+}
+
+// readHosts 逐行读取主机名，忽略空行与 # 注释
+func readHosts(r io.Reader, fn func(host string)) error {
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		host := strings.TrimSpace(strings.SplitN(scanner.Text(), "#", 2)[0])
+		if host != "" {
+			fn(host)
+		}
+	}
+
+	return scanner.Err()
+}
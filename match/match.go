@@ -0,0 +1,135 @@
+package match
+
+import (
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/kr328/domains2providers/output"
+	"github.com/kr328/domains2providers/trie"
+)
+
+type cidrRule struct {
+	rule string
+	cidr *net.IPNet
+}
+
+type regexpRule struct {
+	rule   string
+	regexp *regexp.Regexp
+}
+
+// Set 用于判断主机名或 IP 是否被某个规则集匹配
+type Set struct {
+	behavior string
+	domains  *trie.Trie
+	keywords []string
+	regexps  []regexpRule
+	cidrs    []cidrRule
+}
+
+// New 根据 behavior 解释 rules 创建 Set，无法解析的规则会被忽略
+func New(behavior string, rules []string) *Set {
+	s := &Set{
+		behavior: behavior,
+		domains:  trie.New(),
+	}
+
+	for _, rule := range rules {
+		switch behavior {
+		case output.Domain:
+			_ = s.domains.Add(rule)
+		case output.IPCIDR:
+			s.addCIDR(rule, rule)
+		case output.Classical:
+			s.addClassical(rule)
+		}
+	}
+
+	return s
+}
+
+func (s *Set) addClassical(rule string) {
+	fields := strings.Split(rule, ",")
+	if len(fields) < 2 {
+		return
+	}
+
+	payload := fields[1]
+
+	switch fields[0] {
+	case "DOMAIN":
+		_ = s.domains.Insert(payload, true)
+	case "DOMAIN-SUFFIX":
+		_ = s.domains.Insert(payload, false)
+	case "DOMAIN-KEYWORD":
+		s.keywords = append(s.keywords, payload)
+	case "DOMAIN-REGEX":
+		if r, err := regexp.Compile(payload); err == nil {
+			s.regexps = append(s.regexps, regexpRule{rule: rule, regexp: r})
+		}
+	case "IP-CIDR", "IP-CIDR6":
+		s.addCIDR(rule, payload)
+	}
+}
+
+func (s *Set) addCIDR(rule, payload string) {
+	if _, cidr, err := net.ParseCIDR(payload); err == nil {
+		s.cidrs = append(s.cidrs, cidrRule{rule: rule, cidr: cidr})
+	}
+}
+
+// MatchCIDR 返回完整包含网段 cidr 的规则，网段重叠时检查全部网段而不只是第一个包含其起始地址的网段
+func (s *Set) MatchCIDR(cidr *net.IPNet) (string, bool) {
+	ones, bits := cidr.Mask.Size()
+
+	for _, c := range s.cidrs {
+		cOnes, cBits := c.cidr.Mask.Size()
+		if cBits == bits && cOnes <= ones && c.cidr.Contains(cidr.IP) {
+			return c.rule, true
+		}
+	}
+
+	return "", false
+}
+
+// Match 判断 host（域名或 IP）是否被匹配，返回负责匹配的规则，格式与规则集中的规则一致
+func (s *Set) Match(host string) (string, bool) {
+	if ip := net.ParseIP(host); ip != nil {
+		for _, c := range s.cidrs {
+			if c.cidr.Contains(ip) {
+				return c.rule, true
+			}
+		}
+
+		return "", false
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	if entry, ok := s.domains.Match(host); ok {
+		if s.behavior != output.Classical {
+			return entry, true
+		}
+
+		if strings.HasPrefix(entry, "+.") {
+			return "DOMAIN-SUFFIX," + entry[len("+."):], true
+		}
+
+		return "DOMAIN," + entry, true
+	}
+
+	for _, keyword := range s.keywords {
+		if strings.Contains(host, keyword) {
+			return "DOMAIN-KEYWORD," + keyword, true
+		}
+	}
+
+	for _, r := range s.regexps {
+		if r.regexp.MatchString(host) {
+			return r.rule, true
+		}
+	}
+
+	return "", false
+}
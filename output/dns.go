@@ -0,0 +1,207 @@
+package output
+
+import (
+	"fmt"
+	"io"
+)
+
+// AdGuardHome 输出 AdGuard Home 规则。
+// Upstream 为空时输出屏蔽规则（||qq.com^，完整域名为 |qq.com^），否则输出上游配置（[/qq.com/]223.5.5.5）。
+// 上游配置同样匹配子域名，无法表示完整域名，完整域名会被跳过。
+type AdGuardHome struct {
+	Upstream string
+}
+
+func (a *AdGuardHome) Name() string {
+	return "adguard"
+}
+
+func (a *AdGuardHome) Extension() string {
+	return ".adguard.txt"
+}
+
+func (a *AdGuardHome) Comment() string {
+	return "!"
+}
+
+func (a *AdGuardHome) Write(w io.Writer, behavior string, rules []string) error {
+	return writeDomains(w, behavior, rules, a.line)
+}
+
+func (a *AdGuardHome) line(domain string, suffix bool) string {
+	switch {
+	case a.Upstream != "" && suffix:
+		return fmt.Sprintf("[/%s/]%s\n", domain, a.Upstream)
+	case a.Upstream != "":
+		return ""
+	case suffix:
+		return fmt.Sprintf("||%s^\n", domain)
+	default:
+		return fmt.Sprintf("|%s^\n", domain)
+	}
+}
+
+// Dnsmasq 输出 dnsmasq 配置。
+// Upstream 为空时输出 address=/qq.com/，否则输出 server=/qq.com/223.5.5.5。
+// address 与 server 的域名总是匹配其子域名。完整域名的屏蔽规则为指向 0.0.0.0 与 :: 的 host-record，
+// 只影响该域名本身；server 无法表示完整域名，完整域名会被跳过以免匹配过多。
+type Dnsmasq struct {
+	Upstream string
+}
+
+func (d *Dnsmasq) Name() string {
+	return "dnsmasq"
+}
+
+func (d *Dnsmasq) Extension() string {
+	return ".dnsmasq.conf"
+}
+
+func (d *Dnsmasq) Comment() string {
+	return "#"
+}
+
+func (d *Dnsmasq) Write(w io.Writer, behavior string, rules []string) error {
+	return writeDomains(w, behavior, rules, d.line)
+}
+
+func (d *Dnsmasq) line(domain string, suffix bool) string {
+	switch {
+	case d.Upstream != "" && suffix:
+		return fmt.Sprintf("server=/%s/%s\n", domain, d.Upstream)
+	case d.Upstream != "":
+		return ""
+	case suffix:
+		return fmt.Sprintf("address=/%s/\n", domain)
+	default:
+		return fmt.Sprintf("host-record=%s,0.0.0.0,::\n", domain)
+	}
+}
+
+// SmartDNS 输出 SmartDNS 配置。
+// Group 为空时输出 address /qq.com/#，否则输出 nameserver /qq.com/group。
+// SmartDNS 的域名规则同样会匹配子域名，完整域名会被跳过。
+type SmartDNS struct {
+	Group string
+}
+
+func (s *SmartDNS) Name() string {
+	return "smartdns"
+}
+
+func (s *SmartDNS) Extension() string {
+	return ".smartdns.conf"
+}
+
+func (s *SmartDNS) Comment() string {
+	return "#"
+}
+
+func (s *SmartDNS) Write(w io.Writer, behavior string, rules []string) error {
+	return writeDomains(w, behavior, rules, s.line)
+}
+
+func (s *SmartDNS) line(domain string, suffix bool) string {
+	if !suffix {
+		return ""
+	}
+
+	if s.Group != "" {
+		return fmt.Sprintf("nameserver /%s/%s\n", domain, s.Group)
+	}
+
+	return fmt.Sprintf("address /%s/#\n", domain)
+}
+
+// Unbound 输出 Unbound 配置。
+// Upstream 为空时输出 server 段下的 local-zone 屏蔽规则，否则为每个域名输出 forward-zone。
+// always_nxdomain 的 local-zone 与 forward-zone 都会匹配子域名。完整域名的屏蔽规则为 transparent 的 local-zone
+// 加上指向 0.0.0.0 与 :: 的 local-data，只影响该域名本身；forward-zone 无法表示完整域名，完整域名会被跳过。
+type Unbound struct {
+	Upstream string
+}
+
+func (u *Unbound) Name() string {
+	return "unbound"
+}
+
+func (u *Unbound) Extension() string {
+	return ".unbound.conf"
+}
+
+func (u *Unbound) Comment() string {
+	return "#"
+}
+
+func (u *Unbound) Write(w io.Writer, behavior string, rules []string) error {
+	if u.Upstream == "" {
+		if _, err := io.WriteString(w, "server:\n"); err != nil {
+			return err
+		}
+	}
+
+	return writeDomains(w, behavior, rules, u.line)
+}
+
+func (u *Unbound) line(domain string, suffix bool) string {
+	switch {
+	case u.Upstream != "" && suffix:
+		return fmt.Sprintf("forward-zone:\n    name: \"%s.\"\n    forward-addr: %s\n", domain, u.Upstream)
+	case u.Upstream != "":
+		return ""
+	case suffix:
+		return fmt.Sprintf("    local-zone: \"%s.\" always_nxdomain\n", domain)
+	default:
+		return fmt.Sprintf("    local-zone: \"%s.\" transparent\n    local-data: \"%s. A 0.0.0.0\"\n    local-data: \"%s. AAAA ::\"\n", domain, domain, domain)
+	}
+}
+
+// domainWriter 为逐条输出域名规则的 DNS 格式，line 返回空字符串表示该规则无法表示
+type domainWriter interface {
+	line(domain string, suffix bool) string
+}
+
+// Dropped 返回 writer 按 behavior 写出 rules 时因格式无法表示而跳过的域名规则条数，
+// 如 SmartDNS 与各上游配置中的完整域名。不是 DNS 格式时返回 0
+func Dropped(writer Writer, behavior string, rules []string) int {
+	w, ok := writer.(domainWriter)
+	if !ok || behavior != Domain && behavior != Classical {
+		return 0
+	}
+
+	dropped := 0
+
+	for _, rule := range rules {
+		if domain, suffix, ok := splitDomain(behavior, rule); ok && w.line(domain, suffix) == "" {
+			dropped++
+		}
+	}
+
+	return dropped
+}
+
+// writeDomains 逐条将规则中的域名按 format 写出，format 返回空字符串的规则与 classical 中的关键字、正则与 IP 规则会被跳过，
+// ipcidr 无法转换为 DNS 配置
+func writeDomains(w io.Writer, behavior string, rules []string, format func(domain string, suffix bool) string) error {
+	if behavior != Domain && behavior != Classical {
+		return fmt.Errorf("%w: %s", ErrUnsupportedBehavior, behavior)
+	}
+
+	for _, rule := range rules {
+		domain, suffix, ok := splitDomain(behavior, rule)
+		if !ok {
+			continue
+		}
+
+		line := format(domain, suffix)
+		if line == "" {
+			continue
+		}
+
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDNSWriters(t *testing.T) {
+	rules := []string{"+.qq.com", "www.example.com"}
+
+	tests := []struct {
+		name   string
+		writer Writer
+		want   string
+	}{
+		{
+			name:   "adguard block",
+			writer: &AdGuardHome{},
+			want:   "||qq.com^\n|www.example.com^\n",
+		},
+		{
+			name:   "adguard upstream",
+			writer: &AdGuardHome{Upstream: "223.5.5.5"},
+			want:   "[/qq.com/]223.5.5.5\n",
+		},
+		{
+			name:   "dnsmasq block",
+			writer: &Dnsmasq{},
+			want:   "address=/qq.com/\nhost-record=www.example.com,0.0.0.0,::\n",
+		},
+		{
+			name:   "dnsmasq upstream",
+			writer: &Dnsmasq{Upstream: "223.5.5.5"},
+			want:   "server=/qq.com/223.5.5.5\n",
+		},
+		{
+			name:   "smartdns block",
+			writer: &SmartDNS{},
+			want:   "address /qq.com/#\n",
+		},
+		{
+			name:   "smartdns group",
+			writer: &SmartDNS{Group: "china"},
+			want:   "nameserver /qq.com/china\n",
+		},
+		{
+			name:   "unbound block",
+			writer: &Unbound{},
+			want: "server:\n" +
+				"    local-zone: \"qq.com.\" always_nxdomain\n" +
+				"    local-zone: \"www.example.com.\" transparent\n" +
+				"    local-data: \"www.example.com. A 0.0.0.0\"\n" +
+				"    local-data: \"www.example.com. AAAA ::\"\n",
+		},
+		{
+			name:   "unbound upstream",
+			writer: &Unbound{Upstream: "223.5.5.5"},
+			want:   "forward-zone:\n    name: \"qq.com.\"\n    forward-addr: 223.5.5.5\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+
+			if err := test.writer.Write(buf, Domain, rules); err != nil {
+				t.Fatal(err)
+			}
+
+			if buf.String() != test.want {
+				t.Errorf("got\n%s\nwant\n%s", buf.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestDNSWritersClassical(t *testing.T) {
+	rules := []string{"DOMAIN-SUFFIX,qq.com", "DOMAIN-KEYWORD,ads", "DOMAIN,www.example.com", "IP-CIDR,1.1.1.0/24"}
+
+	buf := &bytes.Buffer{}
+	if err := (&AdGuardHome{}).Write(buf, Classical, rules); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "||qq.com^\n|www.example.com^\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+
+	if err := (&Dnsmasq{}).Write(buf, IPCIDR, rules); err == nil {
+		t.Error("ipcidr should be rejected")
+	}
+}
+
+func TestDropped(t *testing.T) {
+	rules := []string{"+.qq.com", "www.example.com", "example.org"}
+
+	tests := []struct {
+		writer Writer
+		want   int
+	}{
+		{writer: &AdGuardHome{}, want: 0},
+		{writer: &AdGuardHome{Upstream: "223.5.5.5"}, want: 2},
+		{writer: &Dnsmasq{}, want: 0},
+		{writer: &Dnsmasq{Upstream: "223.5.5.5"}, want: 2},
+		{writer: &SmartDNS{}, want: 2},
+		{writer: &Unbound{Upstream: "223.5.5.5"}, want: 2},
+		{writer: Clash{}, want: 0},
+	}
+
+	for _, test := range tests {
+		if got := Dropped(test.writer, Domain, rules); got != test.want {
+			t.Errorf("%s %+v: dropped %d, want %d", test.writer.Name(), test.writer, got, test.want)
+		}
+	}
+
+	if got := Dropped(&SmartDNS{}, Classical, []string{"DOMAIN,www.example.com", "DOMAIN-KEYWORD,ads"}); got != 1 {
+		t.Errorf("classical: dropped %d, want 1", got)
+	}
+}
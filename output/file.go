@@ -0,0 +1,48 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WriteFile 原子地写入文件：先写入同目录下的临时文件，成功后再重命名覆盖目标文件，
+// 避免中途失败留下不完整的文件
+func WriteFile(path string, content []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	cleanup := func(err error) error {
+		_ = temp.Close()
+		_ = os.Remove(temp.Name())
+
+		return err
+	}
+
+	if _, err := temp.Write(content); err != nil {
+		return cleanup(err)
+	}
+
+	if err := temp.Sync(); err != nil {
+		return cleanup(err)
+	}
+
+	if err := temp.Chmod(0644); err != nil {
+		return cleanup(err)
+	}
+
+	if err := temp.Close(); err != nil {
+		_ = os.Remove(temp.Name())
+
+		return err
+	}
+
+	if err := os.Rename(temp.Name(), path); err != nil {
+		_ = os.Remove(temp.Name())
+
+		return err
+	}
+
+	return nil
+}
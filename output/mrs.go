@@ -0,0 +1,156 @@
+package output
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var ErrEmptyRuleset = errors.New("empty ruleset")
+
+// mrsMagic 为 mihomo 二进制规则集 MRSv1 的文件头
+var mrsMagic = [4]byte{'M', 'R', 'S', 1}
+
+// Mrs 输出 mihomo 的二进制规则集（rule-provider 的 format: mrs），只支持 domain 行为。
+// 文件为 zstd 压缩的文件头、行为、规则条数与 mihomo DomainSet 的二进制形式，不支持注释
+type Mrs struct{}
+
+func (Mrs) Name() string {
+	return "mrs"
+}
+
+func (Mrs) Extension() string {
+	return ".mrs"
+}
+
+func (Mrs) Comment() string {
+	return ""
+}
+
+func (Mrs) Write(w io.Writer, behavior string, rules []string) (err error) {
+	if behavior != Domain {
+		return fmt.Errorf("%w: %s", ErrUnsupportedBehavior, behavior)
+	}
+
+	if len(rules) == 0 {
+		return ErrEmptyRuleset
+	}
+
+	// 单线程压缩以保证相同的规则得到相同的文件
+	encoder, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedBestCompression), zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := encoder.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	// 文件头、行为（domain 为 0）、规则条数与保留的附加数据长度
+	if _, err := encoder.Write(append(mrsMagic[:], 0)); err != nil {
+		return err
+	}
+
+	if err := binary.Write(encoder, binary.BigEndian, [2]int64{int64(len(rules)), 0}); err != nil {
+		return err
+	}
+
+	return writeDomainSet(encoder, rules)
+}
+
+// writeDomainSet 按 mihomo DomainSet 的格式写出 rules：反转后的域名组成的 LOUDS 简洁字典树。
+// "+.qq.com" 同时插入 qq.com 与 +.qq.com，与 mihomo 读取文本规则集时一致
+func writeDomainSet(w io.Writer, rules []string) error {
+	keys := make([]string, 0, len(rules)*2)
+
+	for _, rule := range rules {
+		domain, suffix, _ := splitDomain(Domain, rule)
+		if suffix {
+			keys = append(keys, reverse(domain), reverse(domain)+".+")
+		} else {
+			keys = append(keys, reverse(domain))
+		}
+	}
+
+	sort.Strings(keys)
+	keys = slices.Compact(keys)
+
+	var leaves, labelBitmap []uint64
+	var labels []byte
+
+	type element struct{ start, end, column int }
+
+	index := 0
+	queue := []element{{0, len(keys), 0}}
+
+	// 按层遍历字典树，每个节点的子节点标签依次写入 labels，labelBitmap 中每个子节点为 0、节点结束为 1
+	for i := 0; i < len(queue); i++ {
+		e := queue[i]
+		if e.column == len(keys[e.start]) {
+			e.start++
+
+			setBit(&leaves, i, 1)
+		}
+
+		for j := e.start; j < e.end; {
+			from := j
+
+			for ; j < e.end && keys[j][e.column] == keys[from][e.column]; j++ {
+			}
+
+			queue = append(queue, element{from, j, e.column + 1})
+			labels = append(labels, keys[from][e.column])
+			setBit(&labelBitmap, index, 0)
+			index++
+		}
+
+		setBit(&labelBitmap, index, 1)
+		index++
+	}
+
+	if _, err := w.Write([]byte{1}); err != nil {
+		return err
+	}
+
+	for _, words := range [][]uint64{leaves, labelBitmap} {
+		if err := binary.Write(w, binary.BigEndian, int64(len(words))); err != nil {
+			return err
+		}
+
+		if err := binary.Write(w, binary.BigEndian, words); err != nil {
+			return err
+		}
+	}
+
+	if err := binary.Write(w, binary.BigEndian, int64(len(labels))); err != nil {
+		return err
+	}
+
+	_, err := w.Write(labels)
+
+	return err
+}
+
+// setBit 将 bitmap 的第 i 位设置为 v，按需扩展 bitmap，v 为 0 时只扩展
+func setBit(bitmap *[]uint64, i int, v uint64) {
+	for i>>6 >= len(*bitmap) {
+		*bitmap = append(*bitmap, 0)
+	}
+
+	(*bitmap)[i>>6] |= v << uint(i&63)
+}
+
+func reverse(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+
+	return string(b)
+}
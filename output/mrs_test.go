@@ -0,0 +1,56 @@
+package output
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestMrsWrite(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := (Mrs{}).Write(buf, Domain, []string{"+.a", "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := zstd.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// "+.a" 与 "a" 去重后为反转的 a 与 a.+，字典树的节点依次为根、a、.、+，其中 a 与 + 为叶子
+	want := &bytes.Buffer{}
+	want.WriteString("MRS\x01\x00")
+	_ = binary.Write(want, binary.BigEndian, []int64{2, 0})
+	want.WriteByte(1)
+	_ = binary.Write(want, binary.BigEndian, []int64{1, 0b1010, 1, 0b1101010, 3})
+	want.WriteString("a.+")
+
+	if !bytes.Equal(content, want.Bytes()) {
+		t.Errorf("content = %x, want %x", content, want.Bytes())
+	}
+}
+
+func TestMrsWriteUnsupported(t *testing.T) {
+	if err := (Mrs{}).Write(io.Discard, IPCIDR, []string{"1.1.1.0/24"}); !errors.Is(err, ErrUnsupportedBehavior) {
+		t.Errorf("ipcidr: err = %v, want ErrUnsupportedBehavior", err)
+	}
+
+	if err := (Mrs{}).Write(io.Discard, Domain, nil); !errors.Is(err, ErrEmptyRuleset) {
+		t.Errorf("empty: err = %v, want ErrEmptyRuleset", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WriteHeader(buf, Mrs{}, []string{"Generated by test"}); err != nil || buf.Len() != 0 {
+		t.Errorf("header written to mrs: %q, %v", buf.String(), err)
+	}
+}
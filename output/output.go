@@ -0,0 +1,106 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	Domain    = "domain"
+	IPCIDR    = "ipcidr"
+	Classical = "classical"
+)
+
+var (
+	ErrUnsupportedBehavior = errors.New("unsupported behavior")
+)
+
+// Writer 将规则集写为某种格式的文件
+type Writer interface {
+	// Name 返回格式名称，如 clash、adguard
+	Name() string
+	// Extension 返回追加在规则集名称后的文件扩展名
+	Extension() string
+	// Comment 返回注释行的前缀，不支持注释时为空
+	Comment() string
+	// Write 按 behavior 解释 rules 并写出
+	Write(w io.Writer, behavior string, rules []string) error
+}
+
+// Clash 输出 Clash rule-provider 使用的 yaml 格式
+type Clash struct{}
+
+func (Clash) Name() string {
+	return "clash"
+}
+
+func (Clash) Extension() string {
+	return ".yaml"
+}
+
+func (Clash) Comment() string {
+	return "#"
+}
+
+func (Clash) Write(w io.Writer, behavior string, rules []string) error {
+	if _, err := io.WriteString(w, "payload:\n"); err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		var line string
+
+		if behavior == Classical {
+			// classical 规则可能包含正则，使用单引号避免转义
+			line = fmt.Sprintf("  - '%s'\n", strings.ReplaceAll(rule, "'", "''"))
+		} else {
+			line = fmt.Sprintf("  - \"%s\"\n", rule)
+		}
+
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// splitDomain 将规则拆分为域名与是否匹配子域名，"+.qq.com" 与 "DOMAIN-SUFFIX,qq.com" 表示 qq.com 及其子域名。
+// classical 中非域名的规则返回 ok = false
+func splitDomain(behavior, rule string) (domain string, suffix bool, ok bool) {
+	if behavior == Classical {
+		typ, payload, _ := strings.Cut(rule, ",")
+
+		switch typ {
+		case "DOMAIN":
+			return payload, false, true
+		case "DOMAIN-SUFFIX":
+			return payload, true, true
+		default:
+			return "", false, false
+		}
+	}
+
+	if strings.HasPrefix(rule, "+.") {
+		return rule[len("+."):], true, true
+	}
+
+	return rule, false, true
+}
+
+// WriteHeader 以 writer 的注释格式写出 lines，不支持注释的格式（Comment 为空）不写出
+func WriteHeader(w io.Writer, writer Writer, lines []string) error {
+	if writer.Comment() == "" {
+		return nil
+	}
+
+	for _, line := range lines {
+		if _, err := fmt.Fprintf(w, "%s %s\n", writer.Comment(), line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
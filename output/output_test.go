@@ -0,0 +1,89 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestClashWrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		behavior string
+		rules    []string
+		want     string
+	}{
+		{
+			name:     "domain",
+			behavior: Domain,
+			rules:    []string{"+.qq.com", "www.example.com"},
+			want:     "payload:\n  - \"+.qq.com\"\n  - \"www.example.com\"\n",
+		},
+		{
+			name:     "ipcidr",
+			behavior: IPCIDR,
+			rules:    []string{"1.1.1.0/24", "2001:db8::/32"},
+			want:     "payload:\n  - \"1.1.1.0/24\"\n  - \"2001:db8::/32\"\n",
+		},
+		{
+			name:     "classical",
+			behavior: Classical,
+			rules: []string{
+				"DOMAIN,www.example.com",
+				"DOMAIN-SUFFIX,qq.com",
+				"DOMAIN-KEYWORD,ads",
+				`DOMAIN-REGEX,^ad\d+\.example\.org$`,
+				"DOMAIN-REGEX,^it's\\.example$",
+				"IP-CIDR,1.1.1.0/24,no-resolve",
+				"IP-CIDR6,2001:db8::/32",
+			},
+			want: "payload:\n" +
+				"  - 'DOMAIN,www.example.com'\n" +
+				"  - 'DOMAIN-SUFFIX,qq.com'\n" +
+				"  - 'DOMAIN-KEYWORD,ads'\n" +
+				"  - 'DOMAIN-REGEX,^ad\\d+\\.example\\.org$'\n" +
+				"  - 'DOMAIN-REGEX,^it''s\\.example$'\n" +
+				"  - 'IP-CIDR,1.1.1.0/24,no-resolve'\n" +
+				"  - 'IP-CIDR6,2001:db8::/32'\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+
+			if err := (Clash{}).Write(buf, test.behavior, test.rules); err != nil {
+				t.Fatal(err)
+			}
+
+			if buf.String() != test.want {
+				t.Errorf("got\n%s\nwant\n%s", buf.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestSplitDomain(t *testing.T) {
+	tests := []struct {
+		behavior string
+		rule     string
+		domain   string
+		suffix   bool
+		ok       bool
+	}{
+		{Domain, "+.qq.com", "qq.com", true, true},
+		{Domain, "www.qq.com", "www.qq.com", false, true},
+		{Classical, "DOMAIN-SUFFIX,qq.com", "qq.com", true, true},
+		{Classical, "DOMAIN,www.qq.com", "www.qq.com", false, true},
+		{Classical, "DOMAIN-KEYWORD,qq", "", false, false},
+		{Classical, "DOMAIN-REGEX,^qq$", "", false, false},
+		{Classical, "IP-CIDR,1.1.1.0/24", "", false, false},
+		{Classical, "IP-CIDR6,2001:db8::/32", "", false, false},
+	}
+
+	for _, test := range tests {
+		domain, suffix, ok := splitDomain(test.behavior, test.rule)
+		if domain != test.domain || suffix != test.suffix || ok != test.ok {
+			t.Errorf("splitDomain(%s, %s) = %s, %v, %v", test.behavior, test.rule, domain, suffix, ok)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package output
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// ReadClash 读取 Clash 格式的规则集，返回 payload 中的规则
+func ReadClash(r io.Reader) ([]string, error) {
+	var rules []string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "- ") {
+			continue
+		}
+
+		rule := strings.TrimSpace(line[len("- "):])
+
+		switch {
+		case len(rule) >= 2 && rule[0] == '"' && rule[len(rule)-1] == '"':
+			rule = rule[1 : len(rule)-1]
+		case len(rule) >= 2 && rule[0] == '\'' && rule[len(rule)-1] == '\'':
+			rule = strings.ReplaceAll(rule[1:len(rule)-1], "''", "'")
+		}
+
+		if rule != "" {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, scanner.Err()
+}
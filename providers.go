@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/kr328/domains2providers/dlc"
+	"github.com/kr328/domains2providers/manifest"
+	"github.com/kr328/domains2providers/output"
+	"github.com/kr328/domains2providers/raw"
+	"github.com/kr328/domains2providers/rule"
+)
+
+// loadOutputProviders 根据 index.json 读取输出目录中全部 clash 格式的规则集
+func loadOutputProviders(dir string) ([]*provider, error) {
+	index, err := manifest.Load(path.Join(dir, manifest.FileName))
+	if err != nil {
+		return nil, fmt.Errorf("load index: %w", err)
+	}
+
+	var providers []*provider
+
+	for _, entry := range index.Files {
+		if entry.Format != "clash" {
+			continue
+		}
+
+		file, err := os.Open(path.Join(dir, entry.File))
+		if err != nil {
+			return nil, err
+		}
+
+		rules, err := output.ReadClash(file)
+		_ = file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", entry.File, err)
+		}
+
+		providers = append(providers, &provider{
+			Name:     entry.Name,
+			Tag:      entry.Tag,
+			Behavior: entry.Behavior,
+			Rules:    rules,
+			Sources:  entry.Sources,
+			Commit:   entry.Commit,
+		})
+	}
+
+	sortProviders(providers)
+
+	return providers, nil
+}
+
+// resolveProviders 解析 domain-list-community 数据中全部规则集的全部标签，无法解析的规则集记录警告后跳过
+func resolveProviders(location string) ([]*provider, error) {
+	source, err := dlc.Open(location)
+	if err != nil {
+		return nil, fmt.Errorf("open domains: %w", err)
+	}
+	defer source.Close()
+
+	ruleSets, err := rule.ParseDirectory(source.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("load domains: %w", err)
+	}
+
+	var providers []*provider
+
+	for name := range ruleSets {
+		tags, err := rule.Resolve(ruleSets, name)
+		if err != nil {
+			slog.Warn("skip unresolvable ruleset", "name", name, "err", err)
+
+			continue
+		}
+
+		for tag, rules := range tags {
+			providers = append(providers, &provider{
+				Name:     name,
+				Tag:      tag,
+				Behavior: output.Domain,
+				Rules:    rules,
+			})
+		}
+	}
+
+	sortProviders(providers)
+
+	return providers, nil
+}
+
+// loadRawProviders 下载并处理全部原始规则
+func loadRawProviders() ([]*provider, error) {
+	ruleSets, failures := raw.LoadRawSources(raws, nil)
+	if len(failures) > 0 {
+		return nil, fmt.Errorf("load raw resources: %w", failures[0])
+	}
+
+	var providers []*provider
+
+	for _, r := range ruleSets {
+		providers = append(providers, &provider{
+			Name:     r.Name,
+			Behavior: r.Behavior,
+			Rules:    r.Rules,
+		})
+	}
+
+	sortProviders(providers)
+
+	return providers, nil
+}
+
+func sortProviders(providers []*provider) {
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].OutputName() < providers[j].OutputName()
+	})
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveProvidersSkipsUnresolvable(t *testing.T) {
+	repository := t.TempDir()
+	data := filepath.Join(repository, "data")
+
+	if err := os.Mkdir(data, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"good":   "example.com\n",
+		"broken": "include:missing\n",
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(data, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	providers, err := resolveProviders(repository)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(providers) != 1 || providers[0].Name != "good" {
+		t.Errorf("providers = %+v, want only good", providers)
+	}
+}
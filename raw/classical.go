@@ -0,0 +1,129 @@
+package raw
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/kr328/domains2providers/trie"
+)
+
+// classicalTypes 为 classical 输出中保留的规则类型
+var classicalTypes = map[string]bool{
+	"DOMAIN":         true,
+	"DOMAIN-SUFFIX":  true,
+	"DOMAIN-KEYWORD": true,
+	"DOMAIN-REGEX":   true,
+	"IP-CIDR":        true,
+	"IP-CIDR6":       true,
+}
+
+// processClassicalRules 将 classical 规则、CIDR 与普通域名混合的来源统一转换为 classical 规则行，
+// 域名规则通过 trie 去除被后缀覆盖的子域名，其余规则去重后排序
+func processClassicalRules(lines []string) []string {
+	domains := trie.New()
+	others := make(map[string]struct{})
+
+	for _, line := range lines {
+		typ, payload, ok := classicalLine(line)
+
+		switch {
+		case !ok:
+			continue
+		case typ == "DOMAIN":
+			_ = domains.Insert(payload, true)
+		case typ == "DOMAIN-SUFFIX":
+			_ = domains.Insert(payload, false)
+		default:
+			others[typ+","+payload] = struct{}{}
+		}
+	}
+
+	result := make([]string, 0, len(others))
+	for _, domain := range domains.Dump() {
+		if strings.HasPrefix(domain, "+.") {
+			result = append(result, "DOMAIN-SUFFIX,"+domain[len("+."):])
+		} else {
+			result = append(result, "DOMAIN,"+domain)
+		}
+	}
+	for rule := range others {
+		result = append(result, rule)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// classicalLine 将来源中的一行解析为 classical 规则的类型与内容，CIDR 与普通域名分别转换为 IP-CIDR/IP-CIDR6 与 DOMAIN-SUFFIX。
+// 空行、注释与不支持的规则类型返回 ok = false
+func classicalLine(line string) (typ, payload string, ok bool) {
+	line = strings.TrimSpace(line)
+	line = strings.TrimSpace(strings.TrimPrefix(line, "- "))
+	line = strings.Trim(line, "\"'")
+	if line == "" || strings.HasPrefix(line, "#") || line == "payload:" {
+		return "", "", false
+	}
+
+	if typ, payload, ok := strings.Cut(line, ","); ok && strings.ToUpper(typ) == typ {
+		typ = strings.TrimSpace(typ)
+		payload = strings.TrimSpace(payload)
+
+		switch {
+		case !classicalTypes[typ]:
+			return "", "", false
+		case typ == "DOMAIN":
+			return typ, strings.ToLower(payload), true
+		case typ == "DOMAIN-SUFFIX":
+			return typ, strings.TrimPrefix(strings.ToLower(payload), "."), true
+		default:
+			return typ, payload, true
+		}
+	}
+
+	if _, cidr, err := net.ParseCIDR(line); err == nil {
+		if cidr.IP.To4() != nil {
+			return "IP-CIDR", cidr.String(), true
+		}
+
+		return "IP-CIDR6", cidr.String(), true
+	}
+
+	if domain := processDomainLine(strings.TrimPrefix(line, "+.")); domain != "" {
+		return "DOMAIN-SUFFIX", domain, true
+	}
+
+	return "", "", false
+}
+
+// filterBlacklistedClassical 剔除被黑名单覆盖的 DOMAIN 与 DOMAIN-SUFFIX 规则，其他规则保持不变
+func filterBlacklistedClassical(rules, blacklisted []string) []string {
+	var domains []string
+	var filtered []string
+
+	for _, rule := range rules {
+		typ, payload, _ := strings.Cut(rule, ",")
+
+		switch typ {
+		case "DOMAIN":
+			domains = append(domains, payload)
+		case "DOMAIN-SUFFIX":
+			domains = append(domains, "+."+payload)
+		default:
+			filtered = append(filtered, rule)
+		}
+	}
+
+	for _, domain := range filterBlacklistedDomains(domains, blacklisted) {
+		if strings.HasPrefix(domain, "+.") {
+			filtered = append(filtered, "DOMAIN-SUFFIX,"+domain[len("+."):])
+		} else {
+			filtered = append(filtered, "DOMAIN,"+domain)
+		}
+	}
+
+	sort.Strings(filtered)
+
+	return filtered
+}
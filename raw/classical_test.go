@@ -0,0 +1,103 @@
+package raw
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProcessClassicalRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  []string
+	}{
+		{
+			name: "classical",
+			lines: []string{
+				"payload:",
+				"  - DOMAIN,www.Example.com",
+				"  - DOMAIN-SUFFIX,.qq.com",
+				"  - 'DOMAIN-KEYWORD,ads'",
+				"  - \"DOMAIN-REGEX,^ad[0-9]+\\.example\\.org$\"",
+				"  - IP-CIDR,1.1.1.0/24,no-resolve",
+				"  - IP-CIDR6,2001:db8::/32",
+				"  - PROCESS-NAME,curl",
+				"# comment",
+			},
+			want: []string{
+				"DOMAIN,www.example.com",
+				"DOMAIN-KEYWORD,ads",
+				"DOMAIN-REGEX,^ad[0-9]+\\.example\\.org$",
+				"DOMAIN-SUFFIX,qq.com",
+				"IP-CIDR,1.1.1.0/24,no-resolve",
+				"IP-CIDR6,2001:db8::/32",
+			},
+		},
+		{
+			name:  "plain lines",
+			lines: []string{"+.qq.com", "example.com", "10.0.0.1/8", "2001:db8::1/32"},
+			want: []string{
+				"DOMAIN-SUFFIX,example.com",
+				"DOMAIN-SUFFIX,qq.com",
+				"IP-CIDR,10.0.0.0/8",
+				"IP-CIDR6,2001:db8::/32",
+			},
+		},
+		{
+			name:  "subdomains covered by suffix",
+			lines: []string{"DOMAIN-SUFFIX,qq.com", "DOMAIN,www.qq.com", "DOMAIN-SUFFIX,im.qq.com", "DOMAIN,qq.com"},
+			want:  []string{"DOMAIN-SUFFIX,qq.com"},
+		},
+		{
+			name:  "duplicates",
+			lines: []string{"DOMAIN-KEYWORD,ads", "DOMAIN-KEYWORD,ads", "IP-CIDR,1.1.1.0/24", "1.1.1.0/24"},
+			want:  []string{"DOMAIN-KEYWORD,ads", "IP-CIDR,1.1.1.0/24"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := processClassicalRules(test.lines); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFilterBlacklistedClassical(t *testing.T) {
+	rules := []string{
+		"DOMAIN,www.example.com",
+		"DOMAIN-KEYWORD,example",
+		"DOMAIN-SUFFIX,qq.com",
+		"DOMAIN-SUFFIX,google.com",
+		"IP-CIDR,1.1.1.0/24",
+	}
+
+	want := []string{
+		"DOMAIN-KEYWORD,example",
+		"DOMAIN-SUFFIX,google.com",
+		"IP-CIDR,1.1.1.0/24",
+	}
+
+	if got := filterBlacklistedClassical(rules, []string{"+.example.com", "+.qq.com"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCountValidLines(t *testing.T) {
+	tests := []struct {
+		behavior string
+		lines    []string
+		want     int
+	}{
+		{"domain", []string{"qq.com", "+.qq.com", "payload:", "regexp:^a$", "full:www.qq.com"}, 3},
+		{"classical", []string{"payload:", "DOMAIN,qq.com", "PROCESS-NAME,curl", "1.1.1.0/24", "qq.com"}, 3},
+		{"ipcidr", []string{"1.1.1.0/24", "invalid"}, 2},
+	}
+
+	for _, test := range tests {
+		if got := countValidLines(test.behavior, test.lines); got != test.want {
+			t.Errorf("countValidLines(%s, %q) = %d, want %d", test.behavior, test.lines, got, test.want)
+		}
+	}
+}
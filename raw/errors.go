@@ -0,0 +1,44 @@
+package raw
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNotText          = errors.New("not a text file")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrUnexpectedFormat = errors.New("unexpected format")
+	ErrNotLocked        = errors.New("not locked, run update first")
+	ErrTooLarge         = errors.New("response too large")
+)
+
+// FetchError 表示读取某个来源URL失败，Status 为非 2xx 的响应状态，Err 为请求或读取时的错误
+type FetchError struct {
+	Rule   string
+	URL    string
+	Status string
+	Err    error
+}
+
+func (e *FetchError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("load %s from %s: %v", e.Rule, e.URL, e.Err)
+	}
+
+	return fmt.Sprintf("load %s from %s: response %s", e.Rule, e.URL, e.Status)
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// newFetchError 创建规则 rule 读取 url 失败的 FetchError，err 为非 2xx 响应的 FetchError 时沿用其 Status
+func newFetchError(rule, url string, err error) *FetchError {
+	var status *FetchError
+	if errors.As(err, &status) && status.Err == nil {
+		return &FetchError{Rule: rule, URL: url, Status: status.Status}
+	}
+
+	return &FetchError{Rule: rule, URL: url, Err: err}
+}
@@ -0,0 +1,278 @@
+package raw
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kr328/domains2providers/output"
+)
+
+// Lock 为原始规则来源的锁定快照，记录每个来源URL获取时的内容，内容按 sha256 缓存在锁文件旁的目录中。
+// 生成时只读取锁定的缓存，保证同一个锁文件的多次生成结果一致，由 UpdateLock 更新
+type Lock struct {
+	Sources map[string]*LockedSource `json:"sources"`
+
+	path     string
+	cacheDir string
+}
+
+// LockedSource 为一个来源URL锁定的内容
+type LockedSource struct {
+	SHA256      string    `json:"sha256"`
+	Size        int       `json:"size"`
+	Entries     int       `json:"entries"` // 内容中除空行与注释外的行数
+	Fetched     time.Time `json:"fetched"`
+	ContentType string    `json:"content_type,omitempty"`
+	ETag        string    `json:"etag,omitempty"`
+}
+
+// sourceURL 为规则的一个来源URL与解析其内容所用的格式
+type sourceURL struct {
+	URL    string
+	Parser string
+}
+
+// response 为来源URL的响应内容
+type response struct {
+	body        []byte
+	contentType string
+	etag        string
+	locked      bool // 是否读取自锁定的缓存
+}
+
+// LoadLock 读取锁文件 path，文件不存在时返回空的锁。缓存目录为锁文件去掉扩展名后加上 .cache，如 sources.cache
+func LoadLock(path string) (*Lock, error) {
+	lock := &Lock{
+		Sources:  map[string]*LockedSource{},
+		path:     path,
+		cacheDir: strings.TrimSuffix(path, filepath.Ext(path)) + ".cache",
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return lock, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	if lock.Sources == nil {
+		lock.Sources = map[string]*LockedSource{}
+	}
+
+	return lock, nil
+}
+
+// Save 写出锁文件
+func (l *Lock) Save() error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+
+	return output.WriteFile(l.path, append(data, '\n'))
+}
+
+// read 读取已锁定来源的缓存，并校验其 sha256
+func (l *Lock) read(source *LockedSource) (*response, error) {
+	body, err := os.ReadFile(filepath.Join(l.cacheDir, source.SHA256))
+	if err != nil {
+		return nil, fmt.Errorf("read locked content: %w", err)
+	}
+
+	if err := checkChecksum(body, source.SHA256); err != nil {
+		return nil, fmt.Errorf("locked content: %w", err)
+	}
+
+	return &response{body: body, contentType: source.ContentType, etag: source.ETag, locked: true}, nil
+}
+
+// record 锁定 url 的内容并写入缓存，entries 为内容中的规则行数
+func (l *Lock) record(url string, c *response, entries int) error {
+	sum := sha256.Sum256(c.body)
+	hash := hex.EncodeToString(sum[:])
+
+	if err := os.MkdirAll(l.cacheDir, 0755); err != nil {
+		return err
+	}
+
+	if err := output.WriteFile(filepath.Join(l.cacheDir, hash), c.body); err != nil {
+		return err
+	}
+
+	l.Sources[url] = &LockedSource{
+		SHA256:      hash,
+		Size:        len(c.body),
+		Entries:     entries,
+		Fetched:     time.Now().UTC().Truncate(time.Second),
+		ContentType: c.contentType,
+		ETag:        c.etag,
+	}
+
+	return nil
+}
+
+// Prune 移除不再被 raws 使用的来源，并删除不再被引用的缓存
+func (l *Lock) Prune(raws []*Raw) error {
+	used := map[string]bool{}
+	for _, raw := range raws {
+		for _, source := range sourcesOf(raw) {
+			used[source.URL] = true
+		}
+	}
+
+	referenced := map[string]bool{}
+	for url, source := range l.Sources {
+		if !used[url] {
+			delete(l.Sources, url)
+
+			continue
+		}
+
+		referenced[source.SHA256] = true
+	}
+
+	files, err := os.ReadDir(l.cacheDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if !file.IsDir() && !referenced[file.Name()] {
+			if err := os.Remove(filepath.Join(l.cacheDir, file.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// UpdateLock 通过网络重新获取 raws 的全部来源，锁定通过校验的内容。
+// 获取或校验失败的来源保留原来锁定的内容，并通过 failures 返回
+func UpdateLock(lock *Lock, raws []*Raw) (failures []*FetchError) {
+	fetched := map[string]*response{}
+
+	for _, raw := range raws {
+		for _, source := range sourcesOf(raw) {
+			c, ok := fetched[source.URL]
+			if !ok {
+				var err error
+
+				c, err = get(source.URL)
+				if err != nil {
+					failures = append(failures, newFetchError(raw.Name, source.URL, err))
+
+					continue
+				}
+
+				fetched[source.URL] = c
+			}
+
+			lines, err := checkedLines(raw, source.Parser, source.URL, c)
+			if err != nil {
+				failures = append(failures, newFetchError(raw.Name, source.URL, err))
+
+				continue
+			}
+
+			if err := lock.record(source.URL, c, len(lines)); err != nil {
+				failures = append(failures, newFetchError(raw.Name, source.URL, err))
+			}
+		}
+	}
+
+	return failures
+}
+
+// sourcesOf 返回 LoadRawSources 会读取的 raw 的全部来源URL
+func sourcesOf(raw *Raw) []sourceURL {
+	var sources []sourceURL
+
+	for _, url := range raw.SourceUrl {
+		sources = append(sources, sourceURL{URL: url, Parser: raw.Behavior})
+	}
+
+	if raw.Behavior == "domain" || raw.Behavior == "classical" {
+		for _, url := range raw.BlacklistUrl {
+			sources = append(sources, sourceURL{URL: url, Parser: "domain"})
+		}
+
+		for _, url := range raw.ForceIncludeUrl {
+			sources = append(sources, sourceURL{URL: url, Parser: raw.Behavior})
+		}
+	}
+
+	return sources
+}
+
+// fetch 获取 url 的内容，lock 不为 nil 时只读取锁定的缓存，否则通过网络获取
+func fetch(lock *Lock, url string) (*response, error) {
+	if lock == nil {
+		return get(url)
+	}
+
+	source := lock.Sources[url]
+	if source == nil {
+		return nil, ErrNotLocked
+	}
+
+	return lock.read(source)
+}
+
+// maxResponseSize 为来源响应的大小上限，与 domain-list-community 数据的下载上限相同
+var maxResponseSize int64 = 256 << 20
+
+// client 为获取来源所用的 HTTP 客户端，超时包含读取响应内容的时间
+var client = &http.Client{Timeout: 5 * time.Minute}
+
+// get 通过网络获取 url 的内容，非 2xx 的响应返回带 Status 的 FetchError，超过 maxResponseSize 的响应返回 ErrTooLarge
+func get(url string) (*response, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return nil, &FetchError{URL: url, Status: resp.Status}
+	}
+
+	if resp.ContentLength > maxResponseSize {
+		return nil, fmt.Errorf("%w: %d bytes, max %d", ErrTooLarge, resp.ContentLength, maxResponseSize)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(body)) > maxResponseSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, maxResponseSize)
+	}
+
+	return &response{
+		body:        body,
+		contentType: resp.Header.Get("Content-Type"),
+		etag:        resp.Header.Get("ETag"),
+	}, nil
+}
@@ -0,0 +1,162 @@
+package raw
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newSourceServer 返回提供 contents 的服务器，contents 中没有的路径返回 404，内容可在测试中修改
+func newSourceServer(t *testing.T, contents map[string]string) (*httptest.Server, *sync.Mutex) {
+	mu := &sync.Mutex{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		content, ok := contents[r.URL.Path]
+		mu.Unlock()
+
+		if !ok {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("ETag", fmt.Sprintf("%q", r.URL.Path))
+		_, _ = w.Write([]byte(content))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, mu
+}
+
+func TestUpdateLock(t *testing.T) {
+	contents := map[string]string{
+		"/direct":    "example.com\n# comment\n+.example.org\n",
+		"/blacklist": "ads.example.com\n",
+		"/html":      "<html><body>captive portal</body></html>\n",
+	}
+
+	server, mu := newSourceServer(t, contents)
+
+	raws := []*Raw{
+		{Name: "direct", Behavior: "domain", SourceUrl: []string{server.URL + "/direct"}, BlacklistUrl: []string{server.URL + "/blacklist"}},
+		{Name: "broken", Behavior: "domain", SourceUrl: []string{server.URL + "/html", server.URL + "/missing"}},
+	}
+
+	lock, err := LoadLock(filepath.Join(t.TempDir(), "sources.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if failures := UpdateLock(lock, raws); len(failures) != 2 {
+		t.Errorf("failures = %v, want html and missing", failures)
+	}
+
+	direct := lock.Sources[server.URL+"/direct"]
+	if direct == nil || direct.Entries != 2 || direct.ETag != `"/direct"` {
+		t.Fatalf("direct = %+v", direct)
+	}
+
+	if lock.Sources[server.URL+"/blacklist"] == nil {
+		t.Error("blacklist not locked")
+	}
+
+	if lock.Sources[server.URL+"/html"] != nil || lock.Sources[server.URL+"/missing"] != nil {
+		t.Error("invalid sources locked")
+	}
+
+	// 获取失败的来源保留原来锁定的内容
+	mu.Lock()
+	delete(contents, "/direct")
+	mu.Unlock()
+
+	if failures := UpdateLock(lock, raws[:1]); len(failures) != 1 {
+		t.Errorf("failures = %v, want direct", failures)
+	}
+
+	if lock.Sources[server.URL+"/direct"] != direct {
+		t.Error("failed source lost its previous pin")
+	}
+
+	if err := lock.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadLock(lock.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := fetch(loaded, server.URL+"/direct")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !c.locked || string(c.body) != "example.com\n# comment\n+.example.org\n" {
+		t.Errorf("locked content = %q", c.body)
+	}
+
+	if _, err := fetch(loaded, server.URL+"/html"); !errors.Is(err, ErrNotLocked) {
+		t.Errorf("unlocked source: err = %v, want ErrNotLocked", err)
+	}
+}
+
+func TestLockPrune(t *testing.T) {
+	server, _ := newSourceServer(t, map[string]string{
+		"/a": "a.example.com\n",
+		"/b": "b.example.com\n",
+	})
+
+	a := &Raw{Name: "a", Behavior: "domain", SourceUrl: []string{server.URL + "/a"}}
+	b := &Raw{Name: "b", Behavior: "domain", SourceUrl: []string{server.URL + "/b"}}
+
+	lock, err := LoadLock(filepath.Join(t.TempDir(), "sources.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if failures := UpdateLock(lock, []*Raw{a, b}); len(failures) != 0 {
+		t.Fatal(failures)
+	}
+
+	removed := lock.Sources[server.URL+"/b"].SHA256
+
+	if err := lock.Prune([]*Raw{a}); err != nil {
+		t.Fatal(err)
+	}
+
+	if lock.Sources[server.URL+"/b"] != nil {
+		t.Error("unused source kept")
+	}
+
+	if _, err := os.Stat(filepath.Join(lock.cacheDir, removed)); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("cache of unused source kept: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(lock.cacheDir, lock.Sources[server.URL+"/a"].SHA256)); err != nil {
+		t.Errorf("cache of used source removed: %v", err)
+	}
+}
+
+func TestGetTooLarge(t *testing.T) {
+	server, _ := newSourceServer(t, map[string]string{"/small": "example.com\n", "/large": strings.Repeat("example.com\n", 4)})
+
+	defer func(size int64) { maxResponseSize = size }(maxResponseSize)
+	maxResponseSize = 16
+
+	if _, err := get(server.URL + "/small"); err != nil {
+		t.Errorf("small response: %v", err)
+	}
+
+	if _, err := get(server.URL + "/large"); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("large response = %v, want %v", err, ErrTooLarge)
+	}
+}
@@ -0,0 +1,90 @@
+package raw
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/kr328/domains2providers/trie"
+)
+
+// findOrigins 找出每条最终规则由哪些来源引入。
+// 来源中的域名若被某条后缀规则覆盖，则该来源计为这条规则的来源；其他规则按内容完全匹配
+func findOrigins(behavior string, rules []string, byURL map[string][]string) map[string][]string {
+	domains := trie.New()
+	exact := map[string]bool{}
+
+	for _, rule := range rules {
+		switch {
+		case behavior == "domain":
+			_ = domains.Add(rule)
+		case behavior == "classical" && strings.HasPrefix(rule, "DOMAIN,"):
+			_ = domains.Insert(rule[len("DOMAIN,"):], true)
+		case behavior == "classical" && strings.HasPrefix(rule, "DOMAIN-SUFFIX,"):
+			_ = domains.Insert(rule[len("DOMAIN-SUFFIX,"):], false)
+		default:
+			exact[rule] = true
+		}
+	}
+
+	urls := make([]string, 0, len(byURL))
+	for url := range byURL {
+		urls = append(urls, url)
+	}
+
+	sort.Strings(urls)
+
+	origins := map[string][]string{}
+
+	add := func(rule, url string) {
+		list := origins[rule]
+		if len(list) == 0 || list[len(list)-1] != url {
+			origins[rule] = append(list, url)
+		}
+	}
+
+	for _, url := range urls {
+		var entries []string
+
+		switch behavior {
+		case "domain":
+			for _, line := range byURL[url] {
+				if domain := processDomainLine(line); domain != "" {
+					entries = append(entries, domain)
+				}
+			}
+		case "classical":
+			entries = processClassicalRules(byURL[url])
+		default:
+			entries = byURL[url]
+		}
+
+		for _, entry := range entries {
+			if exact[entry] {
+				add(entry, url)
+
+				continue
+			}
+
+			domain := entry
+			if behavior == "classical" {
+				_, domain, _ = strings.Cut(entry, ",")
+			}
+
+			matched, ok := domains.Match(domain)
+			if !ok {
+				continue
+			}
+
+			switch {
+			case behavior != "classical":
+				add(matched, url)
+			case strings.HasPrefix(matched, "+."):
+				add("DOMAIN-SUFFIX,"+matched[len("+."):], url)
+			default:
+				add("DOMAIN,"+matched, url)
+			}
+		}
+	}
+
+	return origins
+}
@@ -1,184 +1,109 @@
 package raw
 
 import (
+    "crypto/sha256"
+    "encoding/hex"
     "fmt"
-    "io"
-    "net/http"
+    "hash"
+    "log/slog"
     neturl "net/url"
     "path"
     "sort"
     "strings"
+    "time"
 )
 
 // Raw 表示原始规则信息
 type Raw struct {
-    Name            string
-    Behavior        string
-    SourceUrl       []string // 普通来源URL列表
-    BlacklistUrl    []string // 黑名单URL列表（仅 Behavior=domain 时生效）
-    ForceIncludeUrl []string // 强制纳入URL列表（仅 Behavior=domain 时生效）
+    Name            string            `json:"name"`
+    Behavior        string            `json:"behavior"`
+    SourceUrl       []string          `json:"source_url"`                  // 普通来源URL列表
+    BlacklistUrl    []string          `json:"blacklist_url,omitempty"`     // 黑名单URL列表（仅 Behavior=domain/classical 时生效）
+    ForceIncludeUrl []string          `json:"force_include_url,omitempty"` // 强制纳入URL列表（仅 Behavior=domain/classical 时生效）
+    Checksums       map[string]string `json:"checksums,omitempty"`         // 可选，URL 到内容 sha256 的固定值，不一致时拒绝该来源
 }
 
 // RuleSet 表示最终处理后的规则集
 type RuleSet struct {
     *Raw
-    Rules []string
+    Rules   []string
+    Origins map[string][]string // 规则到引入该规则的来源URL，被规则覆盖的子域名的来源同样计入
+    Stats   Stats
+    Digest  string            // 全部来源内容的 sha256
+    ETags   map[string]string // 来源URL到响应的 ETag，没有 ETag 的来源不记录
 }
 
-// 这里给出一个初始的 raws，可以按需添加 BlacklistUrl
-var raws = []*Raw{
-    {
-        Name:     "cncidr",
-        Behavior: "ipcidr",
-        SourceUrl: []string{
-            "https://raw.githubusercontent.com/ChanthMiao/China-IPv4-List/release/cn.txt",
-            "https://raw.githubusercontent.com/ChanthMiao/China-IPv6-List/release/cn6.txt",
-        },
-    },
-    {
-        Name:     "lancidr",
-        Behavior: "ipcidr",
-        SourceUrl: []string{
-            "https://raw.githubusercontent.com/v2fly/geoip/release/text/private.txt",
-        },
-    },
-    {
-        Name:     "direct",
-        Behavior: "domain",
-        SourceUrl: []string{
-            "https://raw.githubusercontent.com/v2fly/domain-list-community/release/cn.txt",
-            "https://raw.githubusercontent.com/blackmatrix7/ios_rule_script/master/rule/Clash/ChinaMax/ChinaMax_Domain.txt",
-            "https://raw.githubusercontent.com/gamesofts/V2rayDomains2Clash/generated/geolocation-!cn@cn.yaml",
-        },
-        BlacklistUrl: []string{
-            "https://raw.githubusercontent.com/gamesofts/clash-rules/master/my-proxy.txt",
-        },
-        ForceIncludeUrl: []string{
-            "https://raw.githubusercontent.com/gamesofts/clash-rules/master/my-cn.txt",
-        },
-    },
-    {
-        Name:     "proxy",
-        Behavior: "domain",
-        SourceUrl: []string{
-            "https://raw.githubusercontent.com/v2fly/domain-list-community/release/geolocation-!cn.txt",
-            "https://raw.githubusercontent.com/blackmatrix7/ios_rule_script/master/rule/Clash/Global/Global_Domain.txt",
-        },
-        BlacklistUrl: []string{
-            "https://raw.githubusercontent.com/v2fly/domain-list-community/release/cn.txt",
-            "https://raw.githubusercontent.com/blackmatrix7/ios_rule_script/master/rule/Clash/ChinaMax/ChinaMax_Domain.txt",
-            "https://raw.githubusercontent.com/gamesofts/V2rayDomains2Clash/generated/geolocation-!cn@cn.yaml",
-            "https://raw.githubusercontent.com/gamesofts/clash-rules/master/my-cn.txt",
-        },
-        ForceIncludeUrl: []string{
-            "https://raw.githubusercontent.com/gamesofts/clash-rules/master/my-proxy.txt",
-        },
-    },
-    {
-        Name:     "ntp",
-        Behavior: "domain",
-        SourceUrl: []string{
-            "https://raw.githubusercontent.com/gamesofts/clash-rules/refs/heads/master/ntp.txt",
-        },
-    },
-    {
-        Name:     "adv",
-        Behavior: "domain",
-        SourceUrl: []string{
-            "https://raw.githubusercontent.com/blackmatrix7/ios_rule_script/master/rule/Clash/AdvertisingLite/AdvertisingLite_Domain.txt",
-            "https://raw.githubusercontent.com/TG-Twilight/AWAvenue-Ads-Rule/main/Filters/AWAvenue-Ads-Rule-hosts.txt",
-            "https://raw.githubusercontent.com/gamesofts/clash-rules/master/my-ad.txt",
-        },
-    },
-    {
-        Name:     "media",
-        Behavior: "domain",
-        SourceUrl: []string{
-            "https://raw.githubusercontent.com/blackmatrix7/ios_rule_script/master/rule/Clash/GlobalMedia/GlobalMedia_Domain.txt",
-        },
-    },
-    {
-        Name:     "unreachable",
-        Behavior: "domain",
-        SourceUrl: []string{
-            "https://raw.githubusercontent.com/gamesofts/clash-rules/master/unreachable.txt",
-        },
-    },
-    {
-        Name:     "apple-cert",
-        Behavior: "domain",
-        SourceUrl: []string{
-            "https://raw.githubusercontent.com/gamesofts/clash-rules/master/apple-cert.txt",
-        },
-    },
-    {
-        Name:     "cpic-direct",
-        Behavior: "domain",
-        SourceUrl: []string{
-            "https://raw.githubusercontent.com/v2fly/domain-list-community/release/cn.txt",
-            "https://raw.githubusercontent.com/blackmatrix7/ios_rule_script/master/rule/Clash/ChinaMax/ChinaMax_Domain.txt",
-            "https://raw.githubusercontent.com/gamesofts/V2rayDomains2Clash/generated/geolocation-!cn@cn.yaml",
-        },
-        BlacklistUrl: []string{
-            "https://raw.githubusercontent.com/gamesofts/clash-rules/master/unreachable.txt",
-        },
-        ForceIncludeUrl: []string{
-            "https://raw.githubusercontent.com/gamesofts/clash-rules/master/my-cn.txt",
-        },
-    },
+// Stats 记录处理过程中各步骤增减的规则条数
+type Stats struct {
+    Lines         int // 普通来源的总行数
+    Invalid       int // 无法解析为规则而跳过的行数
+    Deduplicated  int // 重复或被后缀规则覆盖而移除的条数
+    Blacklisted   int // 黑名单移除的条数
+    ForceIncluded int // 强制纳入新增的条数
 }
 
-// LoadRawSources 读取 raws 中所有内容并做必要处理，返回最终的多个 RuleSet
-func LoadRawSources() ([]*RuleSet, error) {
-    var result []*RuleSet
-
+// LoadRawSources 读取 raws 中所有内容并做必要处理，返回最终的多个 RuleSet。
+// 任一来源读取失败的规则会被跳过，失败的来源通过 failures 返回。
+// lock 不为 nil 时只读取锁定的缓存，未锁定的来源视为失败
+func LoadRawSources(raws []*Raw, lock *Lock) (result []*RuleSet, failures []*FetchError) {
     for _, raw := range raws {
         sourceURLs := raw.SourceUrl
+        etags := map[string]string{}
         forceIncludeURLs := append([]string{}, raw.ForceIncludeUrl...)
 
-        // 对 domain 规则，自动把 SourceUrl 里 my-xxx 的地址提升为强制纳入
-        if raw.Behavior == "domain" {
+        filterable := raw.Behavior == "domain" || raw.Behavior == "classical"
+
+        // 对 domain/classical 规则，自动把 SourceUrl 里 my-xxx 的地址提升为强制纳入
+        if filterable {
             var autoForceURLs []string
             sourceURLs, autoForceURLs = splitForceIncludeURLs(raw.SourceUrl)
             forceIncludeURLs = append(forceIncludeURLs, autoForceURLs...)
         }
 
         // 1. 读取普通 SourceUrl 内容
-        sourceLines, err := loadLinesFromURLs(raw.Name, sourceURLs)
+        sourceByURL, err := loadLinesByURL(raw, raw.Behavior, sourceURLs, etags, lock)
         if err != nil {
-            return nil, err
+            failures = append(failures, err)
+            continue
         }
+        sourceLines := flattenLines(sourceURLs, sourceByURL)
 
         // 2. 读取 BlacklistUrl 内容
         var blacklistLines []string
-        if raw.Behavior == "domain" && len(raw.BlacklistUrl) > 0 {
-            blacklistLines, err = loadLinesFromURLs(raw.Name, raw.BlacklistUrl)
+        if filterable && len(raw.BlacklistUrl) > 0 {
+            blacklistLines, err = loadLinesFromURLs(raw, "domain", raw.BlacklistUrl, etags, lock)
             if err != nil {
-                return nil, err
+                failures = append(failures, err)
+                continue
             }
         }
 
         // 3. 读取 ForceIncludeUrl 内容
         var forceIncludeLines []string
-        if raw.Behavior == "domain" && len(forceIncludeURLs) > 0 {
-            forceIncludeLines, err = loadLinesFromURLs(raw.Name, forceIncludeURLs)
+        forceIncludeByURL := map[string][]string{}
+        if filterable && len(forceIncludeURLs) > 0 {
+            forceIncludeByURL, err = loadLinesByURL(raw, raw.Behavior, forceIncludeURLs, etags, lock)
             if err != nil {
-                return nil, err
+                failures = append(failures, err)
+                continue
             }
+            forceIncludeLines = flattenLines(forceIncludeURLs, forceIncludeByURL)
         }
 
-        // 4. 根据不同 Behavior 做处理
-        var processedRules []string
+        // 4. 根据不同 Behavior 做处理，deduplicated/filtered 为去重与黑名单过滤后的规则
+        var processedRules, deduplicated, filtered []string
         switch raw.Behavior {
         case "domain":
             // 4.1 先处理普通来源
             processedRules = processDomainRules(sourceLines)
+            deduplicated = processedRules
 
             // 4.2 再执行黑名单过滤
             if len(blacklistLines) > 0 {
                 blacklistedDomains := processDomainRules(blacklistLines)
                 processedRules = filterBlacklistedDomains(processedRules, blacklistedDomains)
             }
+            filtered = processedRules
 
             // 4.3 最后把强制纳入规则回补，确保不会被黑名单排除
             if len(forceIncludeLines) > 0 {
@@ -186,52 +111,195 @@ func LoadRawSources() ([]*RuleSet, error) {
                 processedRules = mergeForcedDomains(processedRules, forcedDomains)
             }
 
+        case "classical":
+            processedRules = processClassicalRules(sourceLines)
+            deduplicated = processedRules
+
+            if len(blacklistLines) > 0 {
+                blacklistedDomains := processDomainRules(blacklistLines)
+                processedRules = filterBlacklistedClassical(processedRules, blacklistedDomains)
+            }
+            filtered = processedRules
+
+            // 强制纳入的规则与结果一同重新处理，重新做去重/去子域名/排序
+            if len(forceIncludeLines) > 0 {
+                processedRules = processClassicalRules(append(processedRules, forceIncludeLines...))
+            }
+
         case "ipcidr":
             processedRules = sourceLines
+            deduplicated, filtered = sourceLines, sourceLines
 
         default:
             processedRules = sourceLines
+            deduplicated, filtered = sourceLines, sourceLines
+        }
+
+        valid := countValidLines(raw.Behavior, sourceLines)
+
+        stats := Stats{
+            Lines:         len(sourceLines),
+            Invalid:       len(sourceLines) - valid,
+            Deduplicated:  valid - len(deduplicated),
+            Blacklisted:   len(deduplicated) - len(filtered),
+            ForceIncluded: countAdded(filtered, processedRules),
         }
 
+        slog.Info("processed raw rules",
+            "rule", raw.Name,
+            "behavior", raw.Behavior,
+            "lines", stats.Lines,
+            "after_dedup", len(deduplicated),
+            "after_blacklist", len(filtered),
+            "final", len(processedRules),
+        )
+
+        // 5. 记录每条规则由哪些来源引入
+        for url, lines := range forceIncludeByURL {
+            sourceByURL[url] = lines
+        }
+
+        digest := sha256.New()
+        digestLines(digest, sourceURLs, sourceLines)
+        digestLines(digest, raw.BlacklistUrl, blacklistLines)
+        digestLines(digest, forceIncludeURLs, forceIncludeLines)
+
         rs := &RuleSet{
-            Raw:   raw,
-            Rules: processedRules,
+            Raw:     raw,
+            Rules:   processedRules,
+            Origins: findOrigins(raw.Behavior, processedRules, sourceByURL),
+            Stats:   stats,
+            Digest:  hex.EncodeToString(digest.Sum(nil)),
+            ETags:   etags,
         }
         result = append(result, rs)
     }
 
-    return result, nil
+    return result, failures
+}
+
+// digestLines 将来源 urls 与其合并后的行写入 h
+func digestLines(h hash.Hash, urls, lines []string) {
+    for _, url := range urls {
+        _, _ = fmt.Fprintln(h, url)
+    }
+    for _, line := range lines {
+        _, _ = fmt.Fprintln(h, line)
+    }
+}
+
+// countValidLines 返回 lines 中能按 behavior 解析为规则的行数，ipcidr 不做处理，全部视为有效
+func countValidLines(behavior string, lines []string) int {
+    valid := 0
+    for _, line := range lines {
+        switch behavior {
+        case "domain":
+            if processDomainLine(line) == "" {
+                continue
+            }
+        case "classical":
+            if _, _, ok := classicalLine(line); !ok {
+                continue
+            }
+        }
+        valid++
+    }
+    return valid
+}
+
+// countAdded 返回 after 中不在 before 里的规则条数
+func countAdded(before, after []string) int {
+    existing := make(map[string]struct{}, len(before))
+    for _, rule := range before {
+        existing[rule] = struct{}{}
+    }
+
+    added := 0
+    for _, rule := range after {
+        if _, ok := existing[rule]; !ok {
+            added++
+        }
+    }
+    return added
 }
 
 // loadLinesFromURLs 读取多个 URL 的文本内容，按行合并返回（会跳过空行与 # 注释）
-func loadLinesFromURLs(ruleName string, urls []string) ([]string, error) {
-    var lines []string
+func loadLinesFromURLs(raw *Raw, parser string, urls []string, etags map[string]string, lock *Lock) ([]string, *FetchError) {
+    byURL, err := loadLinesByURL(raw, parser, urls, etags, lock)
+    if err != nil {
+        return nil, err
+    }
+
+    return flattenLines(urls, byURL), nil
+}
+
+// loadLinesByURL 读取多个 URL 的文本内容，分别返回每个 URL 的行（会跳过空行与 # 注释）。
+// 响应的 ETag 记录到 etags，lock 不为 nil 时只读取锁定的缓存
+func loadLinesByURL(raw *Raw, parser string, urls []string, etags map[string]string, lock *Lock) (map[string][]string, *FetchError) {
+    ruleName := raw.Name
+    byURL := make(map[string][]string, len(urls))
     for _, url := range urls {
-        resp, err := http.Get(url)
+        start := time.Now()
+
+        c, err := fetch(lock, url)
         if err != nil {
-            return nil, fmt.Errorf("load %s from %s: %v", ruleName, url, err)
-        }
-        if resp.StatusCode/100 != 2 {
-            return nil, fmt.Errorf("load %s from %s: response %s", ruleName, url, resp.Status)
+            return nil, newFetchError(ruleName, url, err)
         }
 
-        content, err := io.ReadAll(resp.Body)
-        _ = resp.Body.Close()
+        lines, err := checkedLines(raw, parser, url, c)
         if err != nil {
-            return nil, fmt.Errorf("load %s from %s: %v", ruleName, url, err)
+            return nil, newFetchError(ruleName, url, err)
+        }
+        byURL[url] = lines
+        if c.etag != "" {
+            etags[url] = c.etag
         }
 
-        for _, line := range strings.Split(string(content), "\n") {
-            line = strings.TrimSpace(line)
-            if line == "" || strings.HasPrefix(line, "#") {
-                continue
-            }
-            lines = append(lines, line)
+        slog.Info("fetched",
+            "rule", ruleName,
+            "url", url,
+            "locked", c.locked,
+            "bytes", len(c.body),
+            "lines", len(lines),
+            "duration", time.Since(start),
+        )
+    }
+    return byURL, nil
+}
+
+// checkedLines 返回来源 url 的内容中的行（跳过空行与 # 注释）。
+// 内容不是纯文本、与 raw.Checksums 不一致或不符合 parser 格式的来源会被拒绝
+func checkedLines(raw *Raw, parser, url string, c *response) ([]string, error) {
+    if err := checkContent(c.contentType, c.body); err != nil {
+        return nil, err
+    }
+    if err := checkChecksum(c.body, raw.Checksums[url]); err != nil {
+        return nil, err
+    }
+
+    var lines []string
+    for _, line := range strings.Split(string(c.body), "\n") {
+        line = strings.TrimSpace(line)
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
         }
+        lines = append(lines, line)
+    }
+    if err := checkFormat(parser, lines); err != nil {
+        return nil, err
     }
     return lines, nil
 }
 
+// flattenLines 按 urls 的顺序合并每个 URL 的行
+func flattenLines(urls []string, byURL map[string][]string) []string {
+    var lines []string
+    for _, url := range urls {
+        lines = append(lines, byURL[url]...)
+    }
+    return lines
+}
+
 // processDomainRules 对域名类规则进行处理（去空、去注释、去前后缀等），并做去重和去子域名操作
 func processDomainRules(rules []string) []string {
     domainSet := make(map[string]struct{})
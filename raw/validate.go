@@ -0,0 +1,121 @@
+package raw
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// minValidRatio 为来源中能被对应解析器识别的行所占的最低比例，低于该比例的来源会被拒绝
+const minValidRatio = 0.5
+
+// checkContent 检查响应是否为纯文本：Content-Type 不能为 HTML，内容嗅探的结果必须为 text/plain
+func checkContent(contentType string, content []byte) error {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if mediaType == "text/html" || mediaType == "application/xhtml+xml" {
+			return fmt.Errorf("%w: content type %s", ErrNotText, mediaType)
+		}
+	}
+
+	if len(content) == 0 {
+		return nil
+	}
+
+	if sniffed := http.DetectContentType(content); !strings.HasPrefix(sniffed, "text/plain") {
+		return fmt.Errorf("%w: content looks like %s", ErrNotText, sniffed)
+	}
+
+	return nil
+}
+
+// checkChecksum 检查内容的 sha256 是否与 want 一致，want 为空时不检查
+func checkChecksum(content []byte, want string) error {
+	if want == "" {
+		return nil
+	}
+
+	sum := sha256.Sum256(content)
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, want) {
+		return fmt.Errorf("%w: got %s, want %s", ErrChecksumMismatch, got, want)
+	}
+
+	return nil
+}
+
+// checkFormat 检查 lines 中能被 parser（domain、ipcidr 或 classical）识别的行是否达到 minValidRatio，
+// 会被解析器忽略的行不计入
+func checkFormat(parser string, lines []string) error {
+	counted, valid := 0, 0
+
+	for _, line := range lines {
+		ok, ignored := validLine(parser, line)
+		if ignored {
+			continue
+		}
+
+		counted++
+		if ok {
+			valid++
+		}
+	}
+
+	if counted > 0 && float64(valid)/float64(counted) < minValidRatio {
+		return fmt.Errorf("%w: only %d of %d lines are valid %s rules", ErrUnexpectedFormat, valid, counted, parser)
+	}
+
+	return nil
+}
+
+// validLine 判断一行是否为 parser 能识别的规则，ignored 表示该行会被解析器直接跳过
+func validLine(parser, line string) (ok, ignored bool) {
+	switch parser {
+	case "ipcidr":
+		if _, _, err := net.ParseCIDR(line); err == nil {
+			return true, false
+		}
+
+		return net.ParseIP(line) != nil, false
+	case "classical":
+		line = strings.TrimSpace(strings.TrimPrefix(line, "- "))
+		line = strings.Trim(line, "\"'")
+		if line == "payload:" {
+			return false, true
+		}
+
+		if typ, _, found := strings.Cut(line, ","); found && strings.ToUpper(typ) == typ {
+			return true, false
+		}
+
+		if _, _, err := net.ParseCIDR(line); err == nil {
+			return true, false
+		}
+
+		return validDomainLine(strings.TrimPrefix(line, "+."))
+	default:
+		return validDomainLine(line)
+	}
+}
+
+// validDomainLine 判断一行经 processDomainLine 处理后是否为域名
+func validDomainLine(line string) (ok, ignored bool) {
+	domain := processDomainLine(line)
+	if domain == "" {
+		return false, true
+	}
+
+	if !strings.Contains(domain, ".") {
+		return false, false
+	}
+
+	for _, c := range domain {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_' || c == '*') {
+			return false, false
+		}
+	}
+
+	return true, false
+}
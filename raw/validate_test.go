@@ -0,0 +1,70 @@
+package raw
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestCheckContent(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		content     string
+		want        error
+	}{
+		{name: "text", contentType: "text/plain; charset=utf-8", content: "example.com\n"},
+		{name: "empty", contentType: "text/plain", content: ""},
+		{name: "html content type", contentType: "text/html; charset=utf-8", content: "example.com\n", want: ErrNotText},
+		{name: "portal page", contentType: "text/plain", content: "<!DOCTYPE html>\n<html><body>Please sign in</body></html>\n", want: ErrNotText},
+		{name: "binary", contentType: "application/octet-stream", content: "\x1f\x8b\x08\x00\x00\x00", want: ErrNotText},
+	}
+
+	for _, test := range tests {
+		if err := checkContent(test.contentType, []byte(test.content)); !errors.Is(err, test.want) {
+			t.Errorf("%s: checkContent = %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		parser string
+		lines  []string
+		want   error
+	}{
+		{name: "domains", parser: "domain", lines: []string{"example.com", "+.example.org", "# comment", ""}},
+		{name: "half valid", parser: "domain", lines: []string{"example.com", "not a domain"}},
+		{name: "below ratio", parser: "domain", lines: []string{"example.com", "not a domain", "<div>", "1 2 3"}, want: ErrUnexpectedFormat},
+		{name: "ipcidr", parser: "ipcidr", lines: []string{"1.1.1.0/24", "2001:db8::/32", "8.8.8.8"}},
+		{name: "domains as ipcidr", parser: "ipcidr", lines: []string{"example.com", "example.org"}, want: ErrUnexpectedFormat},
+		{name: "classical", parser: "classical", lines: []string{"payload:", "  - DOMAIN,example.com", "  - IP-CIDR,1.1.1.0/24"}},
+		{name: "only ignored", parser: "domain", lines: []string{"# comment", ""}},
+	}
+
+	for _, test := range tests {
+		if err := checkFormat(test.parser, test.lines); !errors.Is(err, test.want) {
+			t.Errorf("%s: checkFormat = %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestCheckChecksum(t *testing.T) {
+	content := []byte("example.com\n")
+	sum := sha256.Sum256(content)
+	want := hex.EncodeToString(sum[:])
+
+	if err := checkChecksum(content, ""); err != nil {
+		t.Errorf("no checksum: %v", err)
+	}
+
+	if err := checkChecksum(content, want); err != nil {
+		t.Errorf("matching checksum: %v", err)
+	}
+
+	if err := checkChecksum([]byte("example.org\n"), want); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("mismatching checksum = %v, want %v", err, ErrChecksumMismatch)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/kr328/domains2providers/raw"
+	"github.com/kr328/domains2providers/rule"
+)
+
+// errorKinds 为汇总中错误分类的输出顺序
+var errorKinds = []string{"parse", "include", "fetch", "regression", "other"}
+
+// report 收集运行中不中断生成的错误，在结束时统一输出
+type report struct {
+	errors []error
+}
+
+func (r *report) add(err error) {
+	r.errors = append(r.errors, err)
+}
+
+// summary 记录全部错误及各类错误的数量，有错误且 fail 为 true 时返回非 nil
+func (r *report) summary(fail bool) error {
+	if len(r.errors) == 0 {
+		return nil
+	}
+
+	counts := map[string]int{}
+
+	for _, err := range r.errors {
+		kind := errorKind(err)
+
+		slog.Error("error", "kind", kind, "err", err)
+
+		counts[kind]++
+	}
+
+	attrs := []any{"count", len(r.errors)}
+	for _, kind := range errorKinds {
+		if counts[kind] > 0 {
+			attrs = append(attrs, kind, counts[kind])
+		}
+	}
+
+	slog.Error("errors summary", attrs...)
+
+	if fail {
+		return fmt.Errorf("%d errors", len(r.errors))
+	}
+
+	return nil
+}
+
+// errorKind 返回错误的分类，用于汇总
+func errorKind(err error) string {
+	var (
+		parseErr      *rule.ParseError
+		includeErr    *rule.IncludeError
+		fetchErr      *raw.FetchError
+		regressionErr *regressionError
+	)
+
+	switch {
+	case errors.As(err, &parseErr):
+		return "parse"
+	case errors.As(err, &includeErr):
+		return "include"
+	case errors.As(err, &fetchErr):
+		return "fetch"
+	case errors.As(err, &regressionErr):
+		return "regression"
+	default:
+		return "other"
+	}
+}
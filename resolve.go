@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/kr328/domains2providers/dlc"
+	"github.com/kr328/domains2providers/output"
+	"github.com/kr328/domains2providers/rule"
+)
+
+func runResolve(args []string) error {
+	flags := newFlagSet("resolve")
+	tag := flags.String("tag", "", "tag of the ruleset to print")
+	classical := flags.Bool("classical", false, "resolve as classical rules, keeping keyword and regexp rules")
+	format := flags.String("format", "clash", "output format")
+
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+
+		return errUsage
+	}
+
+	if !contains(formats, *format) {
+		return fmt.Errorf("unknown format %s", *format)
+	}
+
+	source, err := dlc.Open(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("open domains: %w", err)
+	}
+	defer source.Close()
+
+	ruleSets, err := rule.ParseDirectory(source.Dir)
+	if err != nil {
+		return fmt.Errorf("load domains: %w", err)
+	}
+
+	name := flags.Arg(1)
+	behavior := output.Domain
+	resolve := rule.Resolve
+
+	if *classical {
+		behavior = output.Classical
+		resolve = rule.ResolveClassical
+	}
+
+	tags, err := resolve(ruleSets, name)
+	if err != nil {
+		return err
+	}
+
+	rules, ok := tags[*tag]
+	if !ok {
+		return fmt.Errorf("tag %s of %s not found", *tag, name)
+	}
+
+	p := &provider{Name: name, Tag: *tag}
+
+	w := bufio.NewWriter(os.Stdout)
+
+	for _, writer := range writersOf(p.OutputName(), behavior, []string{*format}) {
+		if err := writer.Write(w, behavior, rules); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
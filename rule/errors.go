@@ -0,0 +1,42 @@
+package rule
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrIncludeNotFound = errors.New("not found")
+	ErrIncludeCycle    = errors.New("include cycle")
+)
+
+// ParseError 表示数据文件中无法解析的一行
+type ParseError struct {
+	File string
+	Line int
+	Text string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s:%d: %v: %s", e.File, e.Line, e.Err, e.Text)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// IncludeError 表示无法解析的 include，Chain 为从被解析的规则集到出错规则集的 include 链
+type IncludeError struct {
+	Chain []string
+	Err   error
+}
+
+func (e *IncludeError) Error() string {
+	return fmt.Sprintf("include %s: %v", strings.Join(e.Chain, " -> "), e.Err)
+}
+
+func (e *IncludeError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,73 @@
+package rule
+
+import "sort"
+
+// Dependencies 返回 name 以及它直接或间接 include 的全部规则集，按名称排序。不存在的规则集同样会被返回
+func Dependencies(all map[string]*Ruleset, name string) []string {
+	seen := map[string]bool{}
+	queue := []string{name}
+
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+
+		if seen[name] {
+			continue
+		}
+
+		seen[name] = true
+
+		if set, ok := all[name]; ok {
+			for _, rule := range set.Rules {
+				if rule.Type == Include {
+					queue = append(queue, rule.Payload)
+				}
+			}
+		}
+	}
+
+	return sortedKeys(seen)
+}
+
+// Dependents 返回 names 以及直接或间接 include 了它们的全部规则集，按名称排序。
+// names 中已不存在的规则集同样会被返回
+func Dependents(all map[string]*Ruleset, names []string) []string {
+	includers := map[string][]string{}
+
+	for name, set := range all {
+		for _, rule := range set.Rules {
+			if rule.Type == Include {
+				includers[rule.Payload] = append(includers[rule.Payload], name)
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	queue := append([]string{}, names...)
+
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+
+		if seen[name] {
+			continue
+		}
+
+		seen[name] = true
+
+		queue = append(queue, includers[name]...)
+	}
+
+	return sortedKeys(seen)
+}
+
+func sortedKeys(set map[string]bool) []string {
+	result := make([]string, 0, len(set))
+	for name := range set {
+		result = append(result, name)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
@@ -0,0 +1,353 @@
+package rule
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Diagnostic 为 Lint 发现的一个问题
+type Diagnostic struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (d *Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d: %s", d.File, d.Line, d.Message)
+}
+
+// Lint 检查 domain-list-community 格式的数据目录：未知前缀、非法域名与正则、重复的规则、
+// 被同一文件中后缀规则覆盖的规则、不存在的 include、循环 include 与未使用的属性。
+// selected 判断规则集 name 的属性是否被输出选中，未被 include 筛选或 selected 选中的属性为未使用的属性
+func Lint(directory string, selected func(name, attribute string) bool) ([]*Diagnostic, error) {
+	files, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var diagnostics []*Diagnostic
+
+	sets := map[string]*Ruleset{}
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		content, err := os.ReadFile(path.Join(directory, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		set, d := lintFile(file.Name(), string(content))
+
+		sets[file.Name()] = set
+		diagnostics = append(diagnostics, d...)
+	}
+
+	diagnostics = append(diagnostics, lintIncludes(sets)...)
+	diagnostics = append(diagnostics, lintAttributes(sets, selected)...)
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].File != diagnostics[j].File {
+			return diagnostics[i].File < diagnostics[j].File
+		}
+
+		return diagnostics[i].Line < diagnostics[j].Line
+	})
+
+	return diagnostics, nil
+}
+
+// lintFile 检查单个文件内的问题
+func lintFile(name, content string) (*Ruleset, []*Diagnostic) {
+	var diagnostics []*Diagnostic
+
+	report := func(line int, format string, args ...interface{}) {
+		diagnostics = append(diagnostics, &Diagnostic{
+			File:    name,
+			Line:    line,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	set := &Ruleset{}
+	seen := map[string]int{}
+
+	for index, line := range strings.Split(content, "\n") {
+		rule, err := parseLine(line)
+		if err != nil {
+			report(index+1, "unknown prefix in %q", strings.TrimSpace(line))
+
+			continue
+		}
+
+		if rule == nil {
+			continue
+		}
+
+		rule.Line = index + 1
+
+		switch rule.Type {
+		case Full, Suffix:
+			if !validDomain(rule.Payload) {
+				report(rule.Line, "invalid domain %q", rule.Payload)
+
+				continue
+			}
+		case Regexp:
+			if _, err := regexp.Compile(rule.Payload); err != nil {
+				report(rule.Line, "invalid regexp %q: %v", rule.Payload, err)
+
+				continue
+			}
+		}
+
+		key := fmt.Sprintf("%d:%s", rule.Type, rule.Payload)
+		if first, ok := seen[key]; ok {
+			report(rule.Line, "duplicate of line %d", first)
+
+			continue
+		}
+
+		seen[key] = rule.Line
+
+		set.Rules = append(set.Rules, rule)
+	}
+
+	suffixes := map[string]*Rule{}
+	for _, rule := range set.Rules {
+		if rule.Type == Suffix {
+			suffixes[rule.Payload] = rule
+		}
+	}
+
+	for _, rule := range set.Rules {
+		if rule.Type != Full && rule.Type != Suffix {
+			continue
+		}
+
+		domain := rule.Payload
+		if rule.Type == Suffix {
+			_, domain, _ = strings.Cut(domain, ".")
+		}
+
+		for domain != "" {
+			if covering, ok := suffixes[domain]; ok && covering != rule && containsTags(covering.Tags, rule.Tags) {
+				report(rule.Line, "%s is covered by %s at line %d", rule.Payload, covering.Payload, covering.Line)
+
+				break
+			}
+
+			_, domain, _ = strings.Cut(domain, ".")
+		}
+	}
+
+	return set, diagnostics
+}
+
+// lintIncludes 检查不存在的 include 与循环 include
+func lintIncludes(sets map[string]*Ruleset) []*Diagnostic {
+	var diagnostics []*Diagnostic
+
+	names := make([]string, 0, len(sets))
+	for name := range sets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, rule := range sets[name].Rules {
+			if rule.Type == Include && sets[rule.Payload] == nil {
+				diagnostics = append(diagnostics, &Diagnostic{
+					File:    name,
+					Line:    rule.Line,
+					Message: fmt.Sprintf("include of nonexistent file %s", rule.Payload),
+				})
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := map[string]int{}
+	reported := map[string]bool{}
+
+	var visit func(name string, stack []string)
+	visit = func(name string, stack []string) {
+		state[name] = visiting
+		stack = append(stack, name)
+
+		for _, rule := range sets[name].Rules {
+			if rule.Type != Include || sets[rule.Payload] == nil {
+				continue
+			}
+
+			switch state[rule.Payload] {
+			case unvisited:
+				visit(rule.Payload, stack)
+			case visiting:
+				start := 0
+				for i, n := range stack {
+					if n == rule.Payload {
+						start = i
+					}
+				}
+
+				cycle := append(append([]string{}, stack[start:]...), rule.Payload)
+				key := strings.Join(cycle, " -> ")
+
+				if !reported[key] {
+					reported[key] = true
+
+					diagnostics = append(diagnostics, &Diagnostic{
+						File:    name,
+						Line:    rule.Line,
+						Message: "include cycle " + key,
+					})
+				}
+			}
+		}
+
+		state[name] = visited
+	}
+
+	for _, name := range names {
+		if state[name] == unvisited {
+			visit(name, nil)
+		}
+	}
+
+	return diagnostics
+}
+
+// lintAttributes 检查未使用的属性。include 行上的 @attr 与 @-attr 使用被引入的规则集及其 include 中的 attr，
+// 被 selected 选中的 name@attr 同样使用规则集 name 及其 include 中的 attr。每个文件的每个属性只在第一次出现的行报告
+func lintAttributes(sets map[string]*Ruleset, selected func(name, attribute string) bool) []*Diagnostic {
+	used := map[string]map[string]bool{}
+
+	var use func(name, attribute string)
+	use = func(name, attribute string) {
+		if sets[name] == nil || used[name][attribute] {
+			return
+		}
+
+		if used[name] == nil {
+			used[name] = map[string]bool{}
+		}
+
+		used[name][attribute] = true
+
+		for _, rule := range sets[name].Rules {
+			if rule.Type == Include {
+				use(rule.Payload, attribute)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(sets))
+	attributes := map[string]bool{}
+
+	for name, set := range sets {
+		names = append(names, name)
+
+		for _, rule := range set.Rules {
+			for _, tag := range rule.Tags {
+				if rule.Type == Include {
+					use(rule.Payload, strings.TrimPrefix(tag, "-"))
+				} else {
+					attributes[tag] = true
+				}
+			}
+		}
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		for attribute := range attributes {
+			if selected(name, attribute) {
+				use(name, attribute)
+			}
+		}
+	}
+
+	var diagnostics []*Diagnostic
+
+	for _, name := range names {
+		reported := map[string]bool{}
+
+		for _, rule := range sets[name].Rules {
+			if rule.Type == Include {
+				continue
+			}
+
+			for _, tag := range rule.Tags {
+				if used[name][tag] || reported[tag] {
+					continue
+				}
+
+				reported[tag] = true
+
+				diagnostics = append(diagnostics, &Diagnostic{
+					File:    name,
+					Line:    rule.Line,
+					Message: fmt.Sprintf("attribute @%s is not selected by any include or output", tag),
+				})
+			}
+		}
+	}
+
+	return diagnostics
+}
+
+// validDomain 判断是否为合法的小写域名
+func validDomain(domain string) bool {
+	if domain == "" || len(domain) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// containsTags 判断 tags 是否包含 sub 中的全部标签
+func containsTags(tags, sub []string) bool {
+	for _, s := range sub {
+		found := false
+
+		for _, t := range tags {
+			if t == s {
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
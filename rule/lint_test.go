@@ -0,0 +1,50 @@
+package rule
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLintAttributes(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"google":  "google.com\ngoogle.cn @cn\ngstatic.cn @cn @ads\ngoogle.co.jp @jp\ninclude:youtube",
+		"youtube": "youtube.com @ads\nyoutube.cn @cn\nyoutube.co.jp @jp",
+		"only-cn": "include:google @cn",
+		"unused":  "example.com @unused\nexample.org @unused",
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// @cn 被 include 筛选使用，google@ads 被输出选中，youtube 经由 include 同样被选中
+	selected := func(name, attribute string) bool {
+		return name == "google" && attribute == "ads"
+	}
+
+	diagnostics, err := Lint(dir, selected)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, d := range diagnostics {
+		got = append(got, d.String())
+	}
+
+	want := []string{
+		"google:4: attribute @jp is not selected by any include or output",
+		"unused:1: attribute @unused is not selected by any include or output",
+		"youtube:3: attribute @jp is not selected by any include or output",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diagnostics = %q, want %q", got, want)
+	}
+}
@@ -1,61 +1,47 @@
 package rule
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
 	"os"
 	"path"
 	"strings"
 )
 
+var (
+	ErrUnsupportedRule = errors.New("unsupported rule")
+)
+
 func ParseFile(file string) (*Ruleset, error) {
 	content, err := os.ReadFile(file)
 	if err != nil {
 		return nil, err
 	}
 
-	set := &Ruleset{}
+	sum := sha256.Sum256(content)
 
-	for _, line := range strings.Split(string(content), "\n") {
-		line = strings.TrimSpace(strings.SplitN(line, "#", 2)[0])
-		if line == "" {
-			continue
-		}
+	set := &Ruleset{Digest: hex.EncodeToString(sum[:])}
 
-		fields := strings.Fields(line)
-		if len(fields) == 0 {
-			continue
-		}
+	for index, line := range strings.Split(string(content), "\n") {
+		rule, err := parseLine(line)
+		if err != nil {
+			set.Errors = append(set.Errors, &ParseError{
+				File: path.Base(file),
+				Line: index + 1,
+				Text: strings.TrimSpace(line),
+				Err:  err,
+			})
 
-		rule := &Rule{}
-
-		descriptor := fields[0]
-
-		switch {
-		case !strings.Contains(descriptor, ":"):
-			rule.Type = Suffix
-			rule.Payload = descriptor
-		case strings.HasPrefix(descriptor, "include:"):
-			rule.Type = Include
-			rule.Payload = descriptor[len("include:"):]
-		case strings.HasPrefix(descriptor, "full:"):
-			rule.Type = Full
-			rule.Payload = descriptor[len("full:"):]
-		case strings.HasPrefix(descriptor, "domain:"):
-			rule.Type = Full
-			rule.Payload = descriptor[len("domain:"):]
-		default:
-			println("Unsupported rule: " + line)
 			continue
 		}
 
-		var tags []string
-
-		for i := 1; i < len(fields); i++ {
-			if strings.HasPrefix(fields[i], "@") {
-				tags = append(tags, fields[i][len("@"):])
-			}
+		if rule == nil {
+			continue
 		}
 
-		rule.Tags = tags
+		rule.Line = index + 1
 
 		set.Rules = append(set.Rules, rule)
 	}
@@ -63,6 +49,58 @@ func ParseFile(file string) (*Ruleset, error) {
 	return set, nil
 }
 
+// parseLine 解析一行规则，空行与注释返回 nil
+func parseLine(line string) (*Rule, error) {
+	line = strings.TrimSpace(strings.SplitN(line, "#", 2)[0])
+	if line == "" {
+		return nil, nil
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	rule := &Rule{}
+
+	descriptor := fields[0]
+
+	switch {
+	case !strings.Contains(descriptor, ":"):
+		rule.Type = Suffix
+		rule.Payload = descriptor
+	case strings.HasPrefix(descriptor, "include:"):
+		rule.Type = Include
+		rule.Payload = descriptor[len("include:"):]
+	case strings.HasPrefix(descriptor, "full:"):
+		rule.Type = Full
+		rule.Payload = descriptor[len("full:"):]
+	case strings.HasPrefix(descriptor, "domain:"):
+		rule.Type = Full
+		rule.Payload = descriptor[len("domain:"):]
+	case strings.HasPrefix(descriptor, "keyword:"):
+		rule.Type = Keyword
+		rule.Payload = descriptor[len("keyword:"):]
+	case strings.HasPrefix(descriptor, "regexp:"):
+		rule.Type = Regexp
+		rule.Payload = descriptor[len("regexp:"):]
+	default:
+		return nil, ErrUnsupportedRule
+	}
+
+	var tags []string
+
+	for i := 1; i < len(fields); i++ {
+		if strings.HasPrefix(fields[i], "@") {
+			tags = append(tags, fields[i][len("@"):])
+		}
+	}
+
+	rule.Tags = tags
+
+	return rule, nil
+}
+
 func ParseDirectory(directory string) (map[string]*Ruleset, error) {
 	files, err := os.ReadDir(directory)
 	if err != nil {
@@ -78,6 +116,8 @@ func ParseDirectory(directory string) (map[string]*Ruleset, error) {
 		}
 
 		r[file.Name()] = entry
+
+		slog.Debug("parsed", "file", file.Name(), "rules", len(entry.Rules), "errors", len(entry.Errors))
 	}
 
 	return r, nil
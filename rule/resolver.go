@@ -1,66 +1,219 @@
 package rule
 
 import (
-	"fmt"
+	"log/slog"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/kr328/domains2providers/trie"
 )
 
+type resolved struct {
+	domains  *trie.Trie
+	keywords map[string]struct{}
+	regexps  map[string]struct{}
+	inserted int // 加入 domains 的域名规则条数，含重复与被后缀覆盖的规则
+}
+
 func Resolve(all map[string]*Ruleset, name string) (map[string][]string, error) {
-	tags := map[string]*trie.Trie{}
+	out, _, err := ResolveStats(all, name)
 
-	if err := resolveRecursive(all, name, tags); err != nil {
+	return out, err
+}
+
+// ResolveStats 与 Resolve 相同，同时返回各标签中因重复或被后缀规则覆盖而移除的域名规则条数
+func ResolveStats(all map[string]*Ruleset, name string) (map[string][]string, map[string]int, error) {
+	r, err := ResolveAll(all, name, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return r.Domains, r.Deduplicated, nil
+}
+
+// ResolveClassical 将规则解析为 Clash classical 格式的规则行，包含 keyword 与 regexp 规则
+func ResolveClassical(all map[string]*Ruleset, name string) (map[string][]string, error) {
+	r, err := ResolveAll(all, name, true)
+	if err != nil {
 		return nil, err
 	}
 
-	out := map[string][]string{}
+	return r.Classical, nil
+}
 
-	for tag, domains := range tags {
-		d := domains.Dump()
+// Resolution 为规则集一次解析的结果
+type Resolution struct {
+	Domains      map[string][]string // 各标签的域名规则，同 Resolve
+	Deduplicated map[string]int      // 各标签中因重复或被后缀规则覆盖而移除的域名规则条数
+	Classical    map[string][]string // 各标签的 classical 规则行，同 ResolveClassical
+}
+
+// ResolveAll 只解析一次规则集，同时得到域名规则与 classical 规则行，classical 为 false 时不生成 Classical
+func ResolveAll(all map[string]*Ruleset, name string, classical bool) (*Resolution, error) {
+	start := time.Now()
+
+	tags := map[string]*resolved{}
+
+	if err := resolveRecursive(all, []string{name}, tags, acceptAll); err != nil {
+		return nil, err
+	}
+
+	r := &Resolution{
+		Domains:      map[string][]string{},
+		Deduplicated: map[string]int{},
+	}
+
+	if classical {
+		r.Classical = map[string][]string{}
+	}
+
+	for tag, t := range tags {
+		d := t.domains.Dump()
+
+		if classical {
+			r.Classical[tag] = t.classical(d)
+		}
+
+		if len(d) == 0 {
+			continue
+		}
 
 		sort.Strings(d)
 
-		out[tag] = d
+		r.Domains[tag] = d
+		r.Deduplicated[tag] = t.inserted - len(d)
+	}
+
+	slog.Debug("resolved", "name", name, "tags", len(tags), "classical", classical, "duration", time.Since(start))
+
+	return r, nil
+}
+
+// classical 返回 Clash classical 格式的规则行，domains 为 r.domains 的全部域名规则
+func (r *resolved) classical(domains []string) []string {
+	var lines []string
+
+	for _, domain := range domains {
+		if strings.HasPrefix(domain, "+.") {
+			lines = append(lines, "DOMAIN-SUFFIX,"+domain[len("+."):])
+		} else {
+			lines = append(lines, "DOMAIN,"+domain)
+		}
+	}
+
+	for keyword := range r.keywords {
+		lines = append(lines, "DOMAIN-KEYWORD,"+keyword)
 	}
 
-	return out, nil
+	for regexp := range r.regexps {
+		lines = append(lines, "DOMAIN-REGEX,"+regexp)
+	}
+
+	sort.Strings(lines)
+
+	return lines
+}
+
+// acceptAll 接受全部规则，为未经 include 属性筛选时的 accept
+func acceptAll(*Rule) bool {
+	return true
 }
 
-func resolveRecursive(all map[string]*Ruleset, name string, tags map[string]*trie.Trie) error {
+// resolveRecursive 将 chain 最后一个规则集中被 accept 接受的规则加入 tags，并递归处理其 include。
+// include 行上的属性筛选被引入的规则：@attr 只保留带有 attr 的规则，@-attr 排除带有 attr 的规则，与上游一致
+func resolveRecursive(all map[string]*Ruleset, chain []string, tags map[string]*resolved, accept func(*Rule) bool) error {
+	name := chain[len(chain)-1]
+
 	node := all[name]
 	if node == nil {
-		return fmt.Errorf("rule %s not found", name)
+		return &IncludeError{Chain: chain, Err: ErrIncludeNotFound}
 	}
 
 	for _, rule := range node.Rules {
-		switch rule.Type {
-		case Include:
-			if err := resolveRecursive(all, rule.Payload, tags); err != nil {
-				return err
-			}
-		case Full:
-			for _, tag := range rule.Tags {
-				_ = getOrPutTag(tags, tag).Insert(rule.Payload, true)
+		if rule.Type == Include {
+			for _, included := range chain {
+				if included == rule.Payload {
+					return &IncludeError{Chain: append(chain, rule.Payload), Err: ErrIncludeCycle}
+				}
 			}
 
-			_ = getOrPutTag(tags, "").Insert(rule.Payload, true)
-		case Suffix:
-			for _, tag := range rule.Tags {
-				_ = getOrPutTag(tags, tag).Insert(rule.Payload, false)
+			next := append(append([]string{}, chain...), rule.Payload)
+			if err := resolveRecursive(all, next, tags, withAttributes(accept, rule.Tags)); err != nil {
+				return err
 			}
 
-			_ = getOrPutTag(tags, "").Insert(rule.Payload, false)
+			continue
 		}
+
+		if !accept(rule) {
+			continue
+		}
+
+		for _, tag := range rule.Tags {
+			getOrPutTag(tags, tag).add(rule)
+		}
+
+		getOrPutTag(tags, "").add(rule)
 	}
 
 	return nil
 }
 
-func getOrPutTag(tags map[string]*trie.Trie, name string) *trie.Trie {
+// withAttributes 在 accept 的基础上按 include 行的属性 attributes 筛选规则
+func withAttributes(accept func(*Rule) bool, attributes []string) func(*Rule) bool {
+	if len(attributes) == 0 {
+		return accept
+	}
+
+	return func(rule *Rule) bool {
+		for _, attribute := range attributes {
+			excluded := strings.HasPrefix(attribute, "-")
+			if hasTag(rule, strings.TrimPrefix(attribute, "-")) == excluded {
+				return false
+			}
+		}
+
+		return accept(rule)
+	}
+}
+
+// hasTag 判断规则是否带有属性 tag
+func hasTag(rule *Rule, tag string) bool {
+	for _, t := range rule.Tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *resolved) add(rule *Rule) {
+	switch rule.Type {
+	case Full:
+		if r.domains.Insert(rule.Payload, true) == nil {
+			r.inserted++
+		}
+	case Suffix:
+		if r.domains.Insert(rule.Payload, false) == nil {
+			r.inserted++
+		}
+	case Keyword:
+		r.keywords[rule.Payload] = struct{}{}
+	case Regexp:
+		r.regexps[rule.Payload] = struct{}{}
+	}
+}
+
+func getOrPutTag(tags map[string]*resolved, name string) *resolved {
 	tag, ok := tags[name]
 	if !ok {
-		tag = trie.New()
+		tag = &resolved{
+			domains:  trie.New(),
+			keywords: map[string]struct{}{},
+			regexps:  map[string]struct{}{},
+		}
 		tags[name] = tag
 	}
 
@@ -0,0 +1,161 @@
+package rule
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// parseAll 按 name 到文件内容解析规则集
+func parseAll(t *testing.T, files map[string]string) map[string]*Ruleset {
+	t.Helper()
+
+	all := map[string]*Ruleset{}
+
+	for name, content := range files {
+		set := &Ruleset{}
+
+		for index, line := range strings.Split(content, "\n") {
+			rule, err := parseLine(line)
+			if err != nil {
+				t.Fatalf("%s:%d: %v", name, index+1, err)
+			}
+
+			if rule != nil {
+				set.Rules = append(set.Rules, rule)
+			}
+		}
+
+		all[name] = set
+	}
+
+	return all
+}
+
+func TestResolveClassical(t *testing.T) {
+	all := parseAll(t, map[string]string{
+		"example": "example.com\nfull:www.example.com\nfull:www.example.org @cn\nkeyword:ads\nregexp:^ad[0-9]+\\.example\\.net$ @cn\ninclude:other",
+		"other":   "other.com @cn",
+	})
+
+	tags, err := ResolveClassical(all, "example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"": {
+			"DOMAIN,www.example.org",
+			"DOMAIN-KEYWORD,ads",
+			"DOMAIN-REGEX,^ad[0-9]+\\.example\\.net$",
+			"DOMAIN-SUFFIX,example.com",
+			"DOMAIN-SUFFIX,other.com",
+		},
+		"cn": {
+			"DOMAIN,www.example.org",
+			"DOMAIN-REGEX,^ad[0-9]+\\.example\\.net$",
+			"DOMAIN-SUFFIX,other.com",
+		},
+	}
+
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("got %q, want %q", tags, want)
+	}
+}
+
+func TestResolveIncludeAttributes(t *testing.T) {
+	all := parseAll(t, map[string]string{
+		"google":  "google.com\ngoogle.cn @cn\ngstatic.cn @cn @ads\ninclude:youtube",
+		"youtube": "youtube.com\nyoutube.cn @cn",
+		"only-cn": "include:google @cn",
+		"not-cn":  "include:google @-cn",
+		"cn-ads":  "include:google @cn @ads",
+		"nested":  "include:only-cn @-ads",
+	})
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"only-cn", []string{"+.google.cn", "+.gstatic.cn", "+.youtube.cn"}},
+		{"not-cn", []string{"+.google.com", "+.youtube.com"}},
+		{"cn-ads", []string{"+.gstatic.cn"}},
+		{"nested", []string{"+.google.cn", "+.youtube.cn"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tags, err := Resolve(all, test.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(tags[""], test.want) {
+				t.Errorf("got %q, want %q", tags[""], test.want)
+			}
+		})
+	}
+
+	tags, err := Resolve(all, "only-cn")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"+.gstatic.cn"}; !reflect.DeepEqual(tags["ads"], want) {
+		t.Errorf("ads = %q, want %q", tags["ads"], want)
+	}
+}
+
+func TestResolveStats(t *testing.T) {
+	all := parseAll(t, map[string]string{
+		"example": "example.com\nfull:www.example.com\nimg.example.com @cn\nexample.com\nkeyword:ads\nfull:example.org @cn",
+	})
+
+	tags, deduplicated, err := ResolveStats(all, "example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"+.example.com", "example.org"}; !reflect.DeepEqual(tags[""], want) {
+		t.Errorf("rules = %q, want %q", tags[""], want)
+	}
+
+	// www 与 img 被 example.com 覆盖，example.com 重复，keyword 不计入
+	if want := map[string]int{"": 3, "cn": 0}; !reflect.DeepEqual(deduplicated, want) {
+		t.Errorf("deduplicated = %v, want %v", deduplicated, want)
+	}
+}
+
+func TestResolveAll(t *testing.T) {
+	all := parseAll(t, map[string]string{
+		"example": "example.com\nkeyword:ads @ads\ninclude:other",
+		"other":   "full:example.org",
+	})
+
+	r, err := ResolveAll(all, "example", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	domains, _, _ := ResolveStats(all, "example")
+	if !reflect.DeepEqual(r.Domains, domains) {
+		t.Errorf("domains = %q, want %q", r.Domains, domains)
+	}
+
+	classical, _ := ResolveClassical(all, "example")
+	if !reflect.DeepEqual(r.Classical, classical) {
+		t.Errorf("classical = %q, want %q", r.Classical, classical)
+	}
+
+	// 只有 keyword 规则的标签没有域名规则，但有 classical 规则行
+	if _, ok := r.Domains["ads"]; ok {
+		t.Errorf("ads has domain rules %q", r.Domains["ads"])
+	}
+	if want := []string{"DOMAIN-KEYWORD,ads"}; !reflect.DeepEqual(r.Classical["ads"], want) {
+		t.Errorf("ads = %q, want %q", r.Classical["ads"], want)
+	}
+
+	if r, err := ResolveAll(all, "example", false); err != nil || r.Classical != nil {
+		t.Errorf("classical resolved without being requested: %v %v", r, err)
+	}
+}
@@ -4,6 +4,8 @@ const (
 	Include LineType = iota
 	Full
 	Suffix
+	Keyword
+	Regexp
 )
 
 type LineType int
@@ -12,8 +14,11 @@ type Rule struct {
 	Type    LineType
 	Payload string
 	Tags    []string
+	Line    int
 }
 
 type Ruleset struct {
-	Rules []*Rule
+	Rules  []*Rule
+	Errors []*ParseError // 无法解析而被跳过的行
+	Digest string        // 文件内容的 sha256
 }
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+
+	"github.com/kr328/domains2providers/manifest"
+)
+
+// contentTypes 为输出文件按扩展名对应的 Content-Type，其余文件为 text/plain
+var contentTypes = map[string]string{
+	".yaml": "text/yaml; charset=utf-8",
+	".json": "application/json",
+	".txt":  "text/plain; charset=utf-8",
+	".conf": "text/plain; charset=utf-8",
+	".mrs":  "application/octet-stream",
+}
+
+// servedFile 为已读取的输出文件，文件大小与修改时间不变时复用
+type servedFile struct {
+	size    int64
+	modTime time.Time
+	content []byte
+	gzipped []byte
+	brotli  []byte
+	etag    string
+}
+
+// server 定时生成规则集，并通过 HTTP 提供输出目录中的文件
+type server struct {
+	dir    string
+	stages [][]string // 每次生成依次执行的阶段与参数，第一项为子命令名
+
+	mu        sync.Mutex
+	files     map[string]*servedFile
+	lastRun   time.Time
+	lastError error
+
+	composeMu sync.Mutex
+	composed  composeCache
+	published map[string]publishedDomains // clash 文件名到生成的 domain 规则集
+}
+
+func runServe(args []string) error {
+	flags := newFlagSet("serve")
+	listen := flags.String("listen", ":8080", "address to listen on")
+	interval := flags.Duration("interval", 24*time.Hour, "interval between generations, 0 to only serve existing files")
+	baseURL := flags.String("base-url", "", "base url of this server used in the clash config (default "+defaultBaseURL+")")
+
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+
+		return errUsage
+	}
+
+	dlc, dir := flags.Arg(0), flags.Arg(1)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	configArgs := []string{stageConfig}
+	if *baseURL != "" {
+		configArgs = append(configArgs, "-base-url", *baseURL)
+	}
+
+	s := &server{
+		dir: dir,
+		stages: [][]string{
+			{stageGenerate, dlc, dir},
+			{stageRaw, dir},
+			append(configArgs, dir),
+		},
+		files:     map[string]*servedFile{},
+		published: map[string]publishedDomains{},
+	}
+
+	publishDomains = s.publish
+
+	if *interval > 0 {
+		go s.schedule(*interval)
+	}
+
+	slog.Info("serving", "listen", *listen, "dir", dir, "interval", *interval)
+
+	httpServer := &http.Server{
+		Addr:              *listen,
+		Handler:           s.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return httpServer.ListenAndServe()
+}
+
+// schedule 立即生成一次，之后每隔 interval 生成一次
+func (s *server) schedule(interval time.Duration) {
+	for {
+		s.generate()
+
+		time.Sleep(interval)
+	}
+}
+
+// generate 依次执行全部阶段，阶段失败时已写出的文件仍会提供，后续阶段照常执行
+func (s *server) generate() {
+	start := time.Now()
+
+	var errs []error
+
+	for _, stage := range s.stages {
+		for _, c := range commands {
+			if c.Name != stage[0] {
+				continue
+			}
+
+			if err := c.Run(stage[1:]); err != nil {
+				slog.Error("stage failed", "stage", stage[0], "err", err)
+
+				errs = append(errs, fmt.Errorf("%s: %w", stage[0], err))
+			}
+		}
+	}
+
+	err := errors.Join(errs...)
+
+	slog.Info("generated", "duration", time.Since(start), "failed", len(errs))
+
+	s.mu.Lock()
+	s.lastRun = start
+	s.lastError = err
+	s.mu.Unlock()
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", s.serveHealth)
+	mux.HandleFunc("/compose", s.serveCompose)
+	mux.HandleFunc("/", s.serveFile)
+
+	return mux
+}
+
+// serveHealth 在输出目录中有 index.json 时返回 200，并附带最近一次生成的结果
+func (s *server) serveHealth(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	status := struct {
+		Status    string     `json:"status"`
+		LastRun   *time.Time `json:"last_run,omitempty"`
+		LastError string     `json:"last_error,omitempty"`
+	}{Status: "ok"}
+	if !s.lastRun.IsZero() {
+		lastRun := s.lastRun
+		status.LastRun = &lastRun
+	}
+	if s.lastError != nil {
+		status.LastError = s.lastError.Error()
+	}
+	s.mu.Unlock()
+
+	code := http.StatusOK
+	if _, err := os.Stat(path.Join(s.dir, manifest.FileName)); err != nil {
+		status.Status = "unavailable"
+		code = http.StatusServiceUnavailable
+	}
+
+	content, _ := json.Marshal(status)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(append(content, '\n'))
+}
+
+// serveFile 提供输出目录中的文件，支持 ETag、br 与 gzip
+func (s *server) serveFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if name == "" || strings.Contains(name, "/") || strings.HasPrefix(name, ".") {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	file, err := s.load(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+
+		return
+	} else if err != nil {
+		slog.Error("read file", "file", name, "err", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+
+		return
+	}
+
+	serveContent(w, r, name, file)
+}
+
+// serveContent 按 name 的扩展名设置 Content-Type 并输出 file，按 Accept-Encoding 使用 br 或 gzip。
+// 只有未编码的响应支持 Range
+func serveContent(w http.ResponseWriter, r *http.Request, name string, file *servedFile) {
+	content, etag := file.content, file.etag
+
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+	if encoding != "" {
+		if encoding == "br" {
+			content = file.brotli
+		} else {
+			content = file.gzipped
+		}
+
+		etag = strings.TrimSuffix(file.etag, `"`) + "-" + encoding + `"`
+
+		w.Header().Set("Content-Encoding", encoding)
+
+		// ServeContent 会按编码后的字节计算 Range，编码的响应总是返回完整内容
+		r = r.Clone(r.Context())
+		r.Header.Del("Range")
+		r.Header.Del("If-Range")
+
+		w = noRangesWriter{w}
+	}
+
+	contentType, ok := contentTypes[path.Ext(name)]
+	if !ok {
+		contentType = "text/plain; charset=utf-8"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Vary", "Accept-Encoding")
+
+	// ServeContent 根据 ETag 处理 If-None-Match 并返回 304
+	http.ServeContent(w, r, "", file.modTime, bytes.NewReader(content))
+}
+
+// load 读取输出目录中的文件，并计算 ETag 与 gzip 压缩后的内容
+func (s *server) load(name string) (*servedFile, error) {
+	filePath := path.Join(s.dir, name)
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil, fs.ErrNotExist
+	}
+
+	s.mu.Lock()
+	cached := s.files[name]
+	s.mu.Unlock()
+
+	if cached != nil && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached, nil
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	file := newServedFile(content, info.ModTime())
+
+	s.mu.Lock()
+	s.files[name] = file
+	s.mu.Unlock()
+
+	return file, nil
+}
+
+// newServedFile 计算 content 的 ETag 与 gzip、br 压缩后的内容
+func newServedFile(content []byte, modTime time.Time) *servedFile {
+	gzipped := &bytes.Buffer{}
+	writer := gzip.NewWriter(gzipped)
+	_, _ = writer.Write(content)
+	_ = writer.Close()
+
+	compressed := &bytes.Buffer{}
+	brWriter := brotli.NewWriterLevel(compressed, brotli.DefaultCompression)
+	_, _ = brWriter.Write(content)
+	_ = brWriter.Close()
+
+	sum := sha256.Sum256(content)
+
+	return &servedFile{
+		size:    int64(len(content)),
+		modTime: modTime,
+		content: content,
+		gzipped: gzipped.Bytes(),
+		brotli:  compressed.Bytes(),
+		etag:    `"` + hex.EncodeToString(sum[:16]) + `"`,
+	}
+}
+
+// negotiateEncoding 按 Accept-Encoding 选择响应的编码，br 与 gzip 中 q 值较高者优先，相同时使用 br，都不接受时返回空字符串
+func negotiateEncoding(accept string) string {
+	qualities := map[string]float64{}
+
+	for _, item := range strings.Split(accept, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.TrimSpace(key) == "q" {
+				parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err != nil {
+					parsed = 0
+				}
+
+				q = parsed
+			}
+		}
+
+		qualities[coding] = q
+	}
+
+	quality := func(coding string) float64 {
+		if q, ok := qualities[coding]; ok {
+			return q
+		}
+
+		return qualities["*"]
+	}
+
+	br, gz := quality("br"), quality("gzip")
+
+	switch {
+	case br > 0 && br >= gz:
+		return "br"
+	case gz > 0:
+		return "gzip"
+	default:
+		return ""
+	}
+}
+
+// noRangesWriter 去掉 ServeContent 设置的 Accept-Ranges，用于不支持 Range 的编码响应
+type noRangesWriter struct {
+	http.ResponseWriter
+}
+
+func (w noRangesWriter) WriteHeader(code int) {
+	w.Header().Del("Accept-Ranges")
+
+	w.ResponseWriter.WriteHeader(code)
+}
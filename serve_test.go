@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"br", "br"},
+		{"gzip, deflate, br", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"br;q=0, gzip;q=0", ""},
+		{"*", "br"},
+		{"*;q=0.5, gzip;q=0.8", "gzip"},
+		{"GZIP", "gzip"},
+	}
+
+	for _, test := range tests {
+		if got := negotiateEncoding(test.accept); got != test.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", test.accept, got, test.want)
+		}
+	}
+}
+
+func TestServeContent(t *testing.T) {
+	content := bytes.Repeat([]byte("payload:\n  - '+.qq.com'\n"), 100)
+	file := newServedFile(content, time.Unix(0, 0))
+
+	tests := []struct {
+		accept   string
+		encoding string
+		decode   func(io.Reader) (io.Reader, error)
+	}{
+		{"", "", func(r io.Reader) (io.Reader, error) { return r, nil }},
+		{"gzip", "gzip", func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{"gzip, br", "br", func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil }},
+	}
+
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/proxy.yaml", nil)
+		request.Header.Set("Accept-Encoding", test.accept)
+		request.Header.Set("Range", "bytes=0-9")
+
+		recorder := httptest.NewRecorder()
+		serveContent(recorder, request, "proxy.yaml", file)
+
+		response := recorder.Result()
+
+		if got := response.Header.Get("Content-Encoding"); got != test.encoding {
+			t.Errorf("%q: Content-Encoding = %q, want %q", test.accept, got, test.encoding)
+		}
+
+		if test.encoding == "" {
+			if response.StatusCode != http.StatusPartialContent || recorder.Body.Len() != 10 {
+				t.Errorf("%q: status %d with %d bytes, want a 10 bytes partial response", test.accept, response.StatusCode, recorder.Body.Len())
+			}
+
+			continue
+		}
+
+		if response.StatusCode != http.StatusOK || response.Header.Get("Accept-Ranges") != "" {
+			t.Errorf("%q: status %d, Accept-Ranges %q, want a full response without ranges", test.accept, response.StatusCode, response.Header.Get("Accept-Ranges"))
+		}
+
+		reader, err := test.decode(response.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(decoded, content) {
+			t.Errorf("%q: decoded content differs", test.accept)
+		}
+	}
+}
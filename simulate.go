@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/kr328/domains2providers/clash"
+	"github.com/kr328/domains2providers/match"
+	"github.com/kr328/domains2providers/output"
+)
+
+// simulatedRule 为 Clash rules 中的一条规则
+type simulatedRule struct {
+	Rule      string
+	Policy    string
+	Set       *match.Set // 为 nil 时表示 MATCH
+	IP        bool       // 是否可能匹配 IP，为 true 时域名需要解析后匹配
+	Domain    bool       // 是否可能匹配域名
+	NoResolve bool
+}
+
+func runSimulate(args []string) error {
+	flags := newFlagSet("simulate")
+	configPath := flags.String("config", "", "clash config with rules and rule-providers")
+	dir := flags.String("output", "", "output path containing the providers referenced by the config")
+	geoip := flags.String("geoip", "CN=cncidr,LAN=lancidr", "comma separated GEOIP country to ipcidr provider mapping")
+	resolve := flags.Bool("resolve", false, "resolve hosts via dns for ip rules without no-resolve")
+
+	_ = flags.Parse(args)
+
+	if *configPath == "" || *dir == "" {
+		flags.Usage()
+
+		return errUsage
+	}
+
+	file, err := os.Open(*configPath)
+	if err != nil {
+		return err
+	}
+
+	config, err := clash.ParseConfig(file)
+	_ = file.Close()
+	if err != nil {
+		return fmt.Errorf("parse config: %w", err)
+	}
+
+	countries := map[string]string{}
+	for _, item := range splitList(*geoip) {
+		country, provider, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("invalid geoip mapping %s", item)
+		}
+
+		countries[strings.ToUpper(country)] = provider
+	}
+
+	rules, err := compileRules(config, *dir, countries)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	check := func(host string) {
+		var ips []net.IP
+		looked := false
+
+		lookup := func() []net.IP {
+			if *resolve && !looked {
+				ips, _ = net.LookupIP(host)
+				looked = true
+			}
+
+			return ips
+		}
+
+		for _, r := range rules {
+			if entry, ok := r.match(host, lookup); ok {
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", host, r.Policy, r.Rule, entry)
+
+				return
+			}
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t-\t-\t-\n", host)
+	}
+
+	hosts := flags.Args()
+	if len(hosts) == 0 || (len(hosts) == 1 && hosts[0] == "-") {
+		return readHosts(os.Stdin, check)
+	}
+
+	for _, host := range hosts {
+		check(host)
+	}
+
+	return nil
+}
+
+// compileRules 将 Clash rules 转换为 simulatedRule，规则集从 dir 中读取，不支持的规则会被跳过
+func compileRules(config *clash.Config, dir string, countries map[string]string) ([]*simulatedRule, error) {
+	sets := map[string]*match.Set{}
+
+	loadSet := func(name, file, behavior string) (*match.Set, error) {
+		if set, ok := sets[name]; ok {
+			return set, nil
+		}
+
+		content, err := os.Open(path.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+
+		rules, err := output.ReadClash(content)
+		_ = content.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", file, err)
+		}
+
+		set := match.New(behavior, rules)
+
+		sets[name] = set
+
+		return set, nil
+	}
+
+	var compiled []*simulatedRule
+	skipped := map[string]bool{}
+
+	for _, line := range config.Rules {
+		fields := strings.Split(line, ",")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+
+		typ := strings.ToUpper(fields[0])
+
+		if typ == "MATCH" || typ == "FINAL" {
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("invalid rule %s", line)
+			}
+
+			compiled = append(compiled, &simulatedRule{Rule: line, Policy: fields[1]})
+
+			continue
+		}
+
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("invalid rule %s", line)
+		}
+
+		r := &simulatedRule{
+			Rule:      line,
+			Policy:    fields[2],
+			NoResolve: len(fields) > 3 && fields[3] == "no-resolve",
+		}
+
+		switch typ {
+		case "RULE-SET":
+			provider := config.Providers[fields[1]]
+			if provider == nil {
+				return nil, fmt.Errorf("rule-provider %s not found", fields[1])
+			}
+
+			set, err := loadSet(fields[1], providerFile(provider), provider.Behavior)
+			if err != nil {
+				return nil, fmt.Errorf("load rule-provider %s: %w", fields[1], err)
+			}
+
+			r.Set = set
+			r.Domain = provider.Behavior != output.IPCIDR
+			r.IP = provider.Behavior != output.Domain
+		case "DOMAIN", "DOMAIN-SUFFIX", "DOMAIN-KEYWORD", "DOMAIN-REGEX":
+			r.Set = match.New(output.Classical, []string{typ + "," + fields[1]})
+			r.Domain = true
+		case "IP-CIDR", "IP-CIDR6":
+			r.Set = match.New(output.Classical, []string{typ + "," + fields[1]})
+			r.IP = true
+		case "GEOIP":
+			provider, ok := countries[strings.ToUpper(fields[1])]
+			if !ok {
+				return nil, fmt.Errorf("no provider mapped for GEOIP,%s, use -geoip", fields[1])
+			}
+
+			set, err := loadSet(provider, provider+".yaml", output.IPCIDR)
+			if err != nil {
+				return nil, fmt.Errorf("load geoip provider %s: %w", provider, err)
+			}
+
+			r.Set = set
+			r.IP = true
+		default:
+			if !skipped[typ] {
+				slog.Warn("skip unsupported rule type", "type", typ)
+
+				skipped[typ] = true
+			}
+
+			continue
+		}
+
+		compiled = append(compiled, r)
+	}
+
+	return compiled, nil
+}
+
+// providerFile 返回 rule-provider 在输出目录中对应的文件名，取 path 或 url 的文件名，均为空时为 <name>.yaml
+func providerFile(p *clash.ProviderConfig) string {
+	for _, location := range []string{p.Path, p.URL} {
+		if location != "" {
+			return path.Base(location)
+		}
+	}
+
+	return p.Name + ".yaml"
+}
+
+// match 判断 host 是否匹配该规则，返回负责匹配的条目。
+// 规则没有 no-resolve 时，域名通过 lookup 解析为 IP 匹配 IP 规则
+func (r *simulatedRule) match(host string, lookup func() []net.IP) (string, bool) {
+	if r.Set == nil {
+		return "-", true
+	}
+
+	isIP := net.ParseIP(host) != nil
+
+	if isIP || r.Domain {
+		if entry, ok := r.Set.Match(host); ok {
+			return entry, true
+		}
+	}
+
+	if isIP || !r.IP || r.NoResolve {
+		return "", false
+	}
+
+	for _, ip := range lookup() {
+		if entry, ok := r.Set.Match(ip.String()); ok {
+			return ip.String() + " " + entry, true
+		}
+	}
+
+	return "", false
+}
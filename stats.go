@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/kr328/domains2providers/manifest"
+	"github.com/kr328/domains2providers/output"
+)
+
+// providerStats 为 stats 命令输出的一个规则集的统计
+type providerStats struct {
+	Provider string `json:"provider"`
+	Behavior string `json:"behavior"`
+	Stage    string `json:"stage"`
+	Total    int    `json:"total"`
+	*manifest.Stats
+}
+
+func runStats(args []string) error {
+	flags := newFlagSet("stats")
+	format := flags.String("format", "markdown", "report format: markdown or json")
+
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+
+		return errUsage
+	}
+
+	index, err := manifest.Load(path.Join(flags.Arg(0), manifest.FileName))
+	if err != nil {
+		return err
+	}
+
+	var stats []*providerStats
+
+	for _, entry := range index.Files {
+		if entry.Format != "clash" || entry.Stats == nil {
+			continue
+		}
+
+		stats = append(stats, &providerStats{
+			Provider: strings.TrimSuffix(entry.File, ".yaml"),
+			Behavior: entry.Behavior,
+			Stage:    entry.Stage,
+			Total:    entry.Count,
+			Stats:    entry.Stats,
+		})
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Provider < stats[j].Provider
+	})
+
+	w := bufio.NewWriter(os.Stdout)
+
+	switch *format {
+	case "markdown":
+		writeStatsMarkdown(w, stats)
+	case "json":
+		content, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		_, _ = w.Write(append(content, '\n'))
+	default:
+		return fmt.Errorf("unknown format %s", *format)
+	}
+
+	return w.Flush()
+}
+
+func writeStatsMarkdown(w io.Writer, stats []*providerStats) {
+	_, _ = fmt.Fprintln(w, "| Provider | Behavior | Total | Full | Suffix | Invalid | Deduplicated | Blacklisted | Force included |")
+	_, _ = fmt.Fprintln(w, "| -------- | -------- | ----: | ---: | -----: | ------: | -----------: | ----------: | -------------: |")
+
+	for _, s := range stats {
+		_, _ = fmt.Fprintf(w, "| `%s` | %s | %d | %d | %d | %d | %d | %d | %d |\n",
+			s.Provider, s.Behavior, s.Total, s.Full, s.Suffix, s.Invalid, s.Deduplicated, s.Blacklisted, s.ForceIncluded)
+	}
+
+	for _, s := range stats {
+		if len(s.Sources) == 0 {
+			continue
+		}
+
+		urls := make([]string, 0, len(s.Sources))
+		for url := range s.Sources {
+			urls = append(urls, url)
+		}
+
+		sort.Strings(urls)
+
+		_, _ = fmt.Fprintf(w, "\n<details><summary><code>%s</code> sources</summary>\n\n", s.Provider)
+		_, _ = fmt.Fprintln(w, "| Source | Rules |")
+		_, _ = fmt.Fprintln(w, "| ------ | ----: |")
+
+		for _, url := range urls {
+			_, _ = fmt.Fprintf(w, "| %s | %d |\n", url, s.Sources[url])
+		}
+
+		_, _ = fmt.Fprintln(w, "\n</details>")
+	}
+}
+
+// countDomains 返回完整域名与后缀规则的条数，ipcidr 规则均为 0
+func countDomains(behavior string, rules []string) (full, suffix int) {
+	for _, rule := range rules {
+		switch {
+		case behavior == output.Domain && strings.HasPrefix(rule, "+."):
+			suffix++
+		case behavior == output.Domain:
+			full++
+		case behavior == output.Classical && strings.HasPrefix(rule, "DOMAIN-SUFFIX,"):
+			suffix++
+		case behavior == output.Classical && strings.HasPrefix(rule, "DOMAIN,"):
+			full++
+		}
+	}
+
+	return full, suffix
+}
+
+// countSources 根据规则的来源统计每个来源URL贡献的规则条数
+func countSources(origins map[string][]string) map[string]int {
+	if len(origins) == 0 {
+		return nil
+	}
+
+	counts := map[string]int{}
+
+	for _, urls := range origins {
+		for _, url := range urls {
+			counts[url]++
+		}
+	}
+
+	return counts
+}
@@ -78,6 +78,42 @@ func (t *Trie) Insert(domain string, full bool) error {
 	return nil
 }
 
+// Add 插入 Dump 格式的条目，"+." 开头的条目匹配域名及其子域名，其余为完整匹配
+func (t *Trie) Add(entry string) error {
+	if strings.HasPrefix(entry, "+.") {
+		return t.Insert(entry[len("+."):], false)
+	}
+
+	return t.Insert(entry, true)
+}
+
+// Match 查找匹配 domain 的条目，返回 Dump 格式的条目，如 "+.qq.com" 或 "www.qq.com"
+func (t *Trie) Match(domain string) (string, bool) {
+	parts, err := splitDomain(domain)
+	if err != nil {
+		return "", false
+	}
+
+	node := t.root
+
+	for i := len(parts) - 1; i >= 0; i-- {
+		node = node.children[parts[i]]
+		if node == nil {
+			return "", false
+		}
+
+		if node.children == nil {
+			return "+." + strings.Join(parts[i:], "."), true
+		}
+	}
+
+	if node.matched {
+		return domain, true
+	}
+
+	return "", false
+}
+
 func (t *Trie) Dump() []string {
 	list := make([]string, 0, 1024)
 
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/kr328/domains2providers/raw"
+)
+
+func runUpdate(args []string) error {
+	flags := newFlagSet("update")
+	only := flags.String("only", "", "comma separated raw rulesets whose sources are updated, defaults to all")
+	fail := flags.Bool("fail-on-error", true, "exit with non-zero status if any source failed to fetch or validate")
+
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+
+		return errUsage
+	}
+
+	lock, err := raw.LoadLock(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("load lock: %w", err)
+	}
+
+	previous := make(map[string]*raw.LockedSource, len(lock.Sources))
+	for url, source := range lock.Sources {
+		previous[url] = source
+	}
+
+	patterns := splitList(*only)
+
+	var selected []*raw.Raw
+	for _, r := range raws {
+		if len(patterns) == 0 || matchAny(patterns, r.Name) {
+			selected = append(selected, r)
+		}
+	}
+
+	var r report
+
+	for _, err := range raw.UpdateLock(lock, selected) {
+		r.add(err)
+	}
+
+	if err := lock.Prune(raws); err != nil {
+		return fmt.Errorf("prune lock: %w", err)
+	}
+
+	if err := lock.Save(); err != nil {
+		return fmt.Errorf("save lock: %w", err)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+
+	writeLockDiff(w, previous, lock.Sources)
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	return r.summary(*fail)
+}
+
+// matchAny 判断原始规则 name 是否匹配 patterns 中的任一项
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matchOutput(pattern, name, "") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeLockDiff 以 markdown 表格输出内容有变化的来源更新前后的规则行数
+func writeLockDiff(w io.Writer, previous, current map[string]*raw.LockedSource) {
+	urls := make([]string, 0, len(current))
+	for url := range current {
+		urls = append(urls, url)
+	}
+
+	for url := range previous {
+		if current[url] == nil {
+			urls = append(urls, url)
+		}
+	}
+
+	sort.Strings(urls)
+
+	var rows []string
+	unchanged := 0
+
+	for _, url := range urls {
+		before, after := previous[url], current[url]
+
+		switch {
+		case before == nil:
+			rows = append(rows, fmt.Sprintf("| %s | - | %d | added |", url, after.Entries))
+		case after == nil:
+			rows = append(rows, fmt.Sprintf("| %s | %d | - | removed |", url, before.Entries))
+		case before.SHA256 == after.SHA256:
+			unchanged++
+		default:
+			rows = append(rows, fmt.Sprintf("| %s | %d | %d | %+d |", url, before.Entries, after.Entries, after.Entries-before.Entries))
+		}
+	}
+
+	if len(rows) == 0 {
+		_, _ = fmt.Fprintf(w, "No source changed, %d unchanged.\n", unchanged)
+
+		return
+	}
+
+	_, _ = fmt.Fprintln(w, "| Source | Before | After | Change |")
+	_, _ = fmt.Fprintln(w, "| ------ | -----: | ----: | -----: |")
+
+	for _, row := range rows {
+		_, _ = fmt.Fprintln(w, row)
+	}
+
+	_, _ = fmt.Fprintf(w, "\n%d sources changed, %d unchanged.\n", len(rows), unchanged)
+}
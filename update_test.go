@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kr328/domains2providers/raw"
+)
+
+func TestWriteLockDiff(t *testing.T) {
+	previous := map[string]*raw.LockedSource{
+		"https://a": {SHA256: "1", Entries: 10},
+		"https://b": {SHA256: "2", Entries: 20},
+		"https://c": {SHA256: "3", Entries: 30},
+	}
+
+	current := map[string]*raw.LockedSource{
+		"https://a": {SHA256: "1", Entries: 10},
+		"https://b": {SHA256: "4", Entries: 15},
+		"https://d": {SHA256: "5", Entries: 5},
+	}
+
+	buf := &bytes.Buffer{}
+	writeLockDiff(buf, previous, current)
+
+	want := "| Source | Before | After | Change |\n" +
+		"| ------ | -----: | ----: | -----: |\n" +
+		"| https://b | 20 | 15 | -5 |\n" +
+		"| https://c | 30 | - | removed |\n" +
+		"| https://d | - | 5 | added |\n" +
+		"\n3 sources changed, 1 unchanged.\n"
+
+	if buf.String() != want {
+		t.Errorf("diff = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	writeLockDiff(buf, current, current)
+
+	if want := "No source changed, 3 unchanged.\n"; buf.String() != want {
+		t.Errorf("diff = %q, want %q", buf.String(), want)
+	}
+}
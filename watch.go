@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/kr328/domains2providers/rule"
+)
+
+// fileState 为数据文件的大小与修改时间，用于轮询检测变化
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// scanDirectory 返回目录中全部文件的状态
+func scanDirectory(dir string) (map[string]fileState, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	states := map[string]fileState{}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		states[entry.Name()] = fileState{size: info.Size(), modTime: info.ModTime()}
+	}
+
+	return states, nil
+}
+
+// watchData 轮询数据目录，文件变化时只重新解析变化的文件，并重新生成直接或间接 include 了它们的规则集
+func (g *generator) watchData(d *domainData) error {
+	states, err := scanDirectory(d.dir)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("watching", "dir", d.dir, "interval", g.watchInterval)
+
+	for {
+		time.Sleep(g.watchInterval)
+
+		current, err := scanDirectory(d.dir)
+		if err != nil {
+			slog.Error("scan data directory", "err", err)
+
+			continue
+		}
+
+		var changed []string
+
+		for name, state := range current {
+			if old, ok := states[name]; !ok || old != state {
+				changed = append(changed, name)
+			}
+		}
+
+		for name := range states {
+			if _, ok := current[name]; !ok {
+				changed = append(changed, name)
+			}
+		}
+
+		states = current
+
+		if len(changed) > 0 {
+			sort.Strings(changed)
+
+			g.update(d, changed)
+		}
+	}
+}
+
+// update 重新解析 changed 中的文件，重新生成受影响的规则集与聚合，并更新索引
+func (g *generator) update(d *domainData, changed []string) {
+	start := time.Now()
+
+	g.entries, g.removed, g.pending, g.report, g.aborted, g.domains = nil, nil, nil, report{}, false, nil
+
+	for _, name := range changed {
+		set, err := rule.ParseFile(path.Join(d.dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			delete(d.ruleSets, name)
+
+			continue
+		} else if err != nil {
+			g.report.add(err)
+
+			continue
+		}
+
+		for _, err := range set.Errors {
+			g.report.add(err)
+		}
+
+		d.ruleSets[name] = set
+	}
+
+	affected := rule.Dependents(d.ruleSets, changed)
+
+	// 触发 guardAbort 时不写入任何文件，记录的输出恢复为与输出目录一致
+	outputs, aggregates := maps.Clone(d.outputs), maps.Clone(d.aggregates)
+
+	g.writeRulesets(d, affected)
+	g.writeAggregates(d)
+
+	if g.aborted {
+		g.pending, g.domains = nil, nil
+		d.outputs, d.aggregates = outputs, aggregates
+
+		_ = g.report.summary(false)
+
+		slog.Error("index not updated, aborted by regression guard")
+
+		return
+	}
+
+	g.flush()
+
+	if err := updateIndex(g.dir, g.entries, g.removed, ""); err != nil {
+		g.report.add(err)
+	} else {
+		g.logUpdated()
+		g.publish()
+		g.advancePrevious()
+	}
+
+	_ = g.report.summary(false)
+
+	slog.Info("regenerated",
+		"changed", changed,
+		"rulesets", len(affected),
+		"written", len(g.entries),
+		"removed", len(g.removed),
+		"duration", time.Since(start),
+	)
+}
+
+// advancePrevious 以输出目录中最近一次成功写入的索引作为回归保护比较的上次输出，
+// 否则监视期间的每次更新都会与启动前的输出比较
+func (g *generator) advancePrevious() {
+	g.previousOutput = dirSnapshot(g.dir)
+	g.previous = maps.Clone(g.current)
+}